@@ -6,10 +6,19 @@ import (
 )
 
 var (
-    DatabaseURL = mustGet("DATABASE_URL")
-    JWTSecret   = mustGet("JWT_SECRET")
+    DatabaseURL     = mustGet("DATABASE_URL")
+    JWTSecret       = mustGet("JWT_SECRET")
+    GuildServiceURL = getEnv("GUILD_SERVICE_URL", "http://guild-service:8080")
+    ServiceToken    = os.Getenv("SERVICE_TOKEN") // общий секрет для внутренних маршрутов guild-service
 )
 
+func getEnv(key, def string) string {
+    if v := os.Getenv(key); v != "" {
+        return v
+    }
+    return def
+}
+
 func mustGet(key string) string {
     v := os.Getenv(key)
     if v == "" {
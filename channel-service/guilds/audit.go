@@ -0,0 +1,56 @@
+package guilds
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/yourorg/channel-service/config"
+	"github.com/yourorg/channel-service/models"
+)
+
+// Журнал аудита ведёт guild-service; channel-service только сообщает ему
+// о действиях с каналами через внутренний маршрут с SERVICE_TOKEN.
+
+// Типы записей журнала для каналов
+const (
+	AuditChannelCreate = "CHANNEL_CREATE"
+	AuditChannelUpdate = "CHANNEL_UPDATE"
+	AuditChannelDelete = "CHANNEL_DELETE"
+)
+
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+// ChannelAudit — действие пользователя с каналом: снимки до и после
+type ChannelAudit struct {
+	UserID     string          `json:"userId"`
+	ActionType string          `json:"actionType"`
+	Before     *models.Channel `json:"before"`
+	After      *models.Channel `json:"after"`
+	Reason     string          `json:"reason"` // заголовок X-Audit-Log-Reason как есть
+}
+
+// RecordChannelAudit записывает действие в журнал гильдии канала
+func RecordChannelAudit(a ChannelAudit) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, config.GuildServiceURL+"/internal/audit-logs/channels", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Service-Token", config.ServiceToken)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("guild-service: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("guild-service: POST /internal/audit-logs/channels: status %d", resp.StatusCode)
+	}
+	return nil
+}
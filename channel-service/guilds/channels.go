@@ -0,0 +1,40 @@
+package guilds
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/yourorg/channel-service/config"
+)
+
+// VisibleChannels спрашивает у guild-service (с токеном пользователя), какие каналы
+// гильдии участник может видеть. Статус, отличный от 200 (не участник, нет гильдии),
+// возвращается как есть.
+func VisibleChannels(auth, guildID string) (map[string]bool, int, error) {
+	req, err := http.NewRequest(http.MethodGet, config.GuildServiceURL+"/guilds/"+url.PathEscape(guildID)+"/channels", nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Authorization", auth)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("guild-service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, nil
+	}
+	var channels []struct {
+		ID string `json:"ID"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&channels); err != nil {
+		return nil, 0, fmt.Errorf("guild-service: %w", err)
+	}
+	visible := make(map[string]bool, len(channels))
+	for _, ch := range channels {
+		visible[ch.ID] = true
+	}
+	return visible, http.StatusOK, nil
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourorg/channel-service/guilds"
+	"github.com/yourorg/channel-service/models"
+)
+
+// recordAudit отправляет действие с каналом в журнал аудита guild-service.
+// before нет у создания, after — у удаления. Действие уже выполнено,
+// поэтому ошибка только логируется.
+func recordAudit(c *gin.Context, action string, before, after *models.Channel) {
+	err := guilds.RecordChannelAudit(guilds.ChannelAudit{
+		UserID:     c.GetString("userId"),
+		ActionType: action,
+		Before:     before,
+		After:      after,
+		Reason:     c.GetHeader("X-Audit-Log-Reason"),
+	})
+	if err != nil {
+		log.Printf("audit %s: %v", action, err)
+	}
+}
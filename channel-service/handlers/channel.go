@@ -7,6 +7,7 @@ import (
     "github.com/google/uuid"
     "gorm.io/gorm"
 
+    "github.com/yourorg/channel-service/guilds"
     "github.com/yourorg/channel-service/models"
 )
 
@@ -23,12 +24,28 @@ func GetChannels(db *gorm.DB) gin.HandlerFunc {
             c.JSON(http.StatusBadRequest, gin.H{"error": "invalid guildId"})
             return
         }
+        // Кто что видит, решает guild-service: участие в гильдии и VIEW_CHANNEL с учётом переопределений
+        visible, status, err := guilds.VisibleChannels(c.GetHeader("Authorization"), guildID.String())
+        if err != nil {
+            c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+            return
+        }
+        if status != http.StatusOK {
+            c.JSON(status, gin.H{"error": http.StatusText(status)})
+            return
+        }
         var channels []models.Channel
         if err := db.Where("guild_id = ?", guildID).Find(&channels).Error; err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
-        c.JSON(http.StatusOK, channels)
+        out := make([]models.Channel, 0, len(channels))
+        for _, ch := range channels {
+            if visible[ch.ID.String()] {
+                out = append(out, ch)
+            }
+        }
+        c.JSON(http.StatusOK, out)
     }
 }
 
@@ -57,6 +74,106 @@ func CreateChannel(db *gorm.DB) gin.HandlerFunc {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
+        recordAudit(c, guilds.AuditChannelCreate, nil, &channel)
         c.JSON(http.StatusCreated, channel)
     }
 }
+
+type UpdateChannelInput struct {
+    Name            *string `json:"name"`
+    SlowmodeSeconds *int    `json:"slowmodeSeconds"`
+}
+
+// PATCH /guilds/:guildId/channels/:channelId — меняет только переданные поля
+func UpdateChannel(db *gorm.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        guildID, err := uuid.Parse(c.Param("guildId"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid guildId"})
+            return
+        }
+        channelID, err := uuid.Parse(c.Param("channelId"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channelId"})
+            return
+        }
+
+        var input UpdateChannelInput
+        if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+
+        var channel models.Channel
+        if err := db.First(&channel, "id = ? AND guild_id = ?", channelID, guildID).Error; err != nil {
+            if err == gorm.ErrRecordNotFound {
+                c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            } else {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            }
+            return
+        }
+
+        updates := map[string]interface{}{}
+        if input.Name != nil {
+            if *input.Name == "" {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "name is empty"})
+                return
+            }
+            updates["name"] = *input.Name
+        }
+        if input.SlowmodeSeconds != nil {
+            if *input.SlowmodeSeconds < 0 || *input.SlowmodeSeconds > models.MaxSlowmodeSeconds {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "slowmodeSeconds must be between 0 and 21600"})
+                return
+            }
+            updates["slowmode_seconds"] = *input.SlowmodeSeconds
+        }
+        if len(updates) > 0 {
+            before := channel
+            if err := db.Model(&channel).Updates(updates).Error; err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+            }
+            if err := db.First(&channel, "id = ?", channelID).Error; err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+                return
+            }
+            recordAudit(c, guilds.AuditChannelUpdate, &before, &channel)
+        }
+        c.JSON(http.StatusOK, channel)
+    }
+}
+
+// DELETE /guilds/:guildId/channels/:channelId
+// Переопределения прав канала удаляются каскадом (внешний ключ guild-service)
+func DeleteChannel(db *gorm.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        guildID, err := uuid.Parse(c.Param("guildId"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid guildId"})
+            return
+        }
+        channelID, err := uuid.Parse(c.Param("channelId"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channelId"})
+            return
+        }
+
+        var channel models.Channel
+        if err := db.First(&channel, "id = ? AND guild_id = ?", channelID, guildID).Error; err != nil {
+            if err == gorm.ErrRecordNotFound {
+                c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            } else {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            }
+            return
+        }
+        if err := db.Delete(&channel).Error; err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        recordAudit(c, guilds.AuditChannelDelete, &channel, nil)
+        c.Status(http.StatusNoContent)
+    }
+}
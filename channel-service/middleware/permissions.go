@@ -0,0 +1,65 @@
+package middleware
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/yourorg/channel-service/config"
+)
+
+// Права гильдий считает guild-service; здесь нужны только их биты
+const (
+    PermAdministrator  int64 = 1 << 3
+    PermManageChannels int64 = 1 << 4
+)
+
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+// RequireGuildPermission спрашивает у guild-service права пользователя в гильдии :guildId
+// (с его же токеном) и пропускает, только если есть perm
+func RequireGuildPermission(perm int64) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        perms, status, err := guildPermissions(c.GetHeader("Authorization"), c.Param("guildId"))
+        if err != nil {
+            c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+            return
+        }
+        if status != http.StatusOK {
+            // 403 (не участник) и 404 отдаём как есть
+            c.AbortWithStatusJSON(status, gin.H{"error": http.StatusText(status)})
+            return
+        }
+        if perms&PermAdministrator == 0 && perms&perm != perm {
+            c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing permissions"})
+            return
+        }
+        c.Next()
+    }
+}
+
+func guildPermissions(auth, guildID string) (int64, int, error) {
+    req, err := http.NewRequest(http.MethodGet, config.GuildServiceURL+"/guilds/"+url.PathEscape(guildID)+"/permissions", nil)
+    if err != nil {
+        return 0, 0, err
+    }
+    req.Header.Set("Authorization", auth)
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return 0, 0, fmt.Errorf("guild-service: %w", err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return 0, resp.StatusCode, nil
+    }
+    var out struct {
+        Permissions int64 `json:"permissions"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+        return 0, 0, fmt.Errorf("guild-service: %w", err)
+    }
+    return out.Permissions, http.StatusOK, nil
+}
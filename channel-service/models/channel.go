@@ -14,12 +14,17 @@ const (
     ChannelTypeVoice ChannelType = "VOICE"
 )
 
+// MaxSlowmodeSeconds — верхняя граница медленного режима (6 часов)
+const MaxSlowmodeSeconds = 21600
+
 type Channel struct {
     ID        uuid.UUID   `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
     GuildID   uuid.UUID   `gorm:"type:uuid;not null;index"`
     Name      string      `gorm:"not null"`
     Type      ChannelType `gorm:"type:channel_type;not null"`
     CreatedAt time.Time   `gorm:"autoCreateTime"`
+    // Медленный режим: сколько секунд участник ждёт между сообщениями; 0 — выключен
+    SlowmodeSeconds int `gorm:"not null;default:0"`
 }
 
 func (c *Channel) BeforeCreate(tx *gorm.DB) (err error) {
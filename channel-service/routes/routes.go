@@ -11,8 +11,10 @@ import (
 func Register(r *gin.Engine, db *gorm.DB) {
     auth := r.Group("/", middleware.JWTAuth())
     {
+        manage := middleware.RequireGuildPermission(middleware.PermManageChannels)
         auth.GET("/guilds/:guildId/channels", handlers.GetChannels(db))
-        auth.POST("/guilds/:guildId/channels", handlers.CreateChannel(db))
-        // позже можно добавить PATCH/DELETE
+        auth.POST("/guilds/:guildId/channels", manage, handlers.CreateChannel(db))
+        auth.PATCH("/guilds/:guildId/channels/:channelId", manage, handlers.UpdateChannel(db))
+        auth.DELETE("/guilds/:guildId/channels/:channelId", manage, handlers.DeleteChannel(db))
     }
 }
@@ -0,0 +1,72 @@
+package broker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/yourorg/chat-service/config"
+)
+
+// Шина между репликами chat-service. Каждый узел публикует свои события
+// и получает события всех узлов; свои же пропускает по Envelope.Node,
+// чтобы клиенты не получили кадр дважды.
+
+// Кому адресован кадр
+const (
+	TargetChannel = "channel" // подписчикам канала
+	TargetUser    = "user"    // всем сессиям пользователя
+	TargetForget  = "forget"  // не кадр: забыть закэшированные права пользователей из Payload (JSON-массив ID)
+)
+
+// Envelope — кадр WS вместе с адресом доставки
+type Envelope struct {
+	Node    string          `json:"node"`
+	Target  string          `json:"target"`
+	ID      string          `json:"id"` // channelID или userID
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Broker — транспорт событий между узлами
+type Broker interface {
+	Publish(ctx context.Context, env Envelope) error
+	// Subscribe вызывает handler на каждое событие, включая свои, пока не отменён ctx
+	Subscribe(ctx context.Context, handler func(Envelope)) error
+	Close() error
+}
+
+// NewNodeID выдаёт уникальный ID узла: имя хоста и случайный суффикс
+func NewNodeID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%s", host, hex.EncodeToString(b))
+}
+
+// New создаёт брокер по config.BrokerBackend
+func New() (Broker, error) {
+	switch config.BrokerBackend {
+	case "memory":
+		return NewMemory(), nil
+	case "redis":
+		return NewRedis(config.RedisAddr, config.BrokerTopic)
+	case "kafka":
+		return NewKafka(config.KafkaBrokers, config.BrokerTopic)
+	default:
+		return nil, fmt.Errorf("unknown BROKER_BACKEND %q", config.BrokerBackend)
+	}
+}
+
+// MustNew — New с выходом при ошибке, для старта сервиса
+func MustNew() Broker {
+	b, err := New()
+	if err != nil {
+		log.Fatalf("broker: %v", err)
+	}
+	log.Printf("broker: %s", config.BrokerBackend)
+	return b
+}
@@ -0,0 +1,111 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Kafka — шина на топике Kafka. Каждый узел читает все партиции
+// с конца без consumer group, поэтому событие получают все реплики.
+// Ключ сообщения — адрес доставки: события одного канала идут по порядку.
+type Kafka struct {
+	client   sarama.Client
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func NewKafka(brokers []string, topic string) (*Kafka, error) {
+	cfg := sarama.NewConfig()
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.RequiredAcks = sarama.WaitForLocal
+
+	// Kafka в compose поднимается дольше сервиса — пробуем несколько раз
+	var (
+		client sarama.Client
+		err    error
+	)
+	for i := 0; i < 10; i++ {
+		client, err = sarama.NewClient(brokers, cfg)
+		if err == nil {
+			break
+		}
+		log.Printf("broker kafka: attempt %d failed: %v", i+1, err)
+		time.Sleep(3 * time.Second)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &Kafka{client: client, producer: producer, topic: topic}, nil
+}
+
+func (k *Kafka) Publish(ctx context.Context, env Envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	_, _, err = k.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: k.topic,
+		Key:   sarama.StringEncoder(env.Target + ":" + env.ID),
+		Value: sarama.ByteEncoder(data),
+	})
+	return err
+}
+
+func (k *Kafka) Subscribe(ctx context.Context, handler func(Envelope)) error {
+	consumer, err := sarama.NewConsumerFromClient(k.client)
+	if err != nil {
+		return err
+	}
+	partitions, err := consumer.Partitions(k.topic)
+	if err != nil {
+		consumer.Close()
+		return err
+	}
+
+	for _, p := range partitions {
+		pc, err := consumer.ConsumePartition(k.topic, p, sarama.OffsetNewest)
+		if err != nil {
+			consumer.Close()
+			return err
+		}
+		go func() {
+			defer pc.Close()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg, ok := <-pc.Messages():
+					if !ok {
+						return
+					}
+					var env Envelope
+					if err := json.Unmarshal(msg.Value, &env); err != nil {
+						log.Println("broker kafka: bad envelope:", err)
+						continue
+					}
+					handler(env)
+				}
+			}
+		}()
+	}
+	go func() {
+		<-ctx.Done()
+		consumer.Close()
+	}()
+	return nil
+}
+
+func (k *Kafka) Close() error {
+	k.producer.Close()
+	return k.client.Close()
+}
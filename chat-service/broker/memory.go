@@ -0,0 +1,44 @@
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory — шина внутри процесса: один узел или несколько хабов в тестах
+type Memory struct {
+	mu       sync.RWMutex
+	handlers map[int]func(Envelope)
+	next     int
+}
+
+func NewMemory() *Memory {
+	return &Memory{handlers: make(map[int]func(Envelope))}
+}
+
+func (m *Memory) Publish(ctx context.Context, env Envelope) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, h := range m.handlers {
+		h(env)
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, handler func(Envelope)) error {
+	m.mu.Lock()
+	id := m.next
+	m.next++
+	m.handlers[id] = handler
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		delete(m.handlers, id)
+		m.mu.Unlock()
+	}()
+	return nil
+}
+
+func (m *Memory) Close() error { return nil }
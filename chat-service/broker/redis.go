@@ -0,0 +1,67 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis — шина на Redis pub/sub. Доставка at-most-once:
+// узел, отключившийся от Redis, пропускает события за это время.
+type Redis struct {
+	rdb     *redis.Client
+	channel string
+}
+
+func NewRedis(addr, channel string) (*Redis, error) {
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+	return &Redis{rdb: rdb, channel: channel}, nil
+}
+
+func (r *Redis) Publish(ctx context.Context, env Envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return r.rdb.Publish(ctx, r.channel, data).Err()
+}
+
+func (r *Redis) Subscribe(ctx context.Context, handler func(Envelope)) error {
+	sub := r.rdb.Subscribe(ctx, r.channel)
+	// Дожидаемся подтверждения подписки, чтобы не потерять первые события
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return err
+	}
+
+	go func() {
+		defer sub.Close()
+		ch := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				var env Envelope
+				if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+					log.Println("broker redis: bad envelope:", err)
+					continue
+				}
+				handler(env)
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *Redis) Close() error {
+	return r.rdb.Close()
+}
@@ -0,0 +1,37 @@
+package cluster
+
+import (
+	"context"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/yourorg/chat-service/config"
+)
+
+// Состояние, общее для всех реплик chat-service: кто из пользователей подключён
+// и какая реплика выполняет фоновую задачу.
+// Бэкенд local годится для одной реплики — там каждый узел видит только себя;
+// redis нужен, как только реплик несколько.
+
+var rdb *redis.Client // nil — бэкенд local
+
+// Init подключается к бэкенду по config.ClusterBackend
+func Init() {
+	switch config.ClusterBackend {
+	case "local":
+	case "redis":
+		rdb = redis.NewClient(&redis.Options{Addr: config.RedisAddr})
+		if err := rdb.Ping(context.Background()).Err(); err != nil {
+			log.Fatalf("cluster: redis: %v", err)
+		}
+	default:
+		log.Fatalf("unknown CLUSTER_BACKEND %q", config.ClusterBackend)
+	}
+	log.Printf("cluster: %s", config.ClusterBackend)
+}
+
+// Shared сообщает, что состояние общее для реплик
+func Shared() bool {
+	return rdb != nil
+}
@@ -0,0 +1,24 @@
+package cluster
+
+import (
+	"context"
+	"time"
+)
+
+// TryLock занимает блокировку name на ttl, если её никто не держит.
+// Так периодическую задачу выполняет одна реплика: кто первым взял блокировку
+// на этот период, тот и работает. Без общего состояния блокировка всегда свободна.
+func TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	if rdb == nil {
+		return true, nil
+	}
+	return rdb.SetNX(ctx, "lock:"+name, 1, ttl).Result()
+}
+
+// Unlock освобождает блокировку name раньше срока
+func Unlock(ctx context.Context, name string) error {
+	if rdb == nil {
+		return nil
+	}
+	return rdb.Del(ctx, "lock:"+name).Err()
+}
@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Присутствие в Redis: на пользователя — sorted set узлов, где у него открыты соединения,
+// score — до какого момента запись действительна. Узел продлевает свои записи каждые
+// presenceTTL/3, поэтому записи упавшего узла сами перестают учитываться.
+
+const presenceTTL = time.Minute
+
+func presenceKey(userID string) string {
+	return "presence:" + strings.ToLower(userID)
+}
+
+// MarkOnline отмечает, что у пользователей есть соединения с узлом node
+func MarkOnline(ctx context.Context, node string, userIDs []string) error {
+	if rdb == nil || len(userIDs) == 0 {
+		return nil
+	}
+	expires := float64(time.Now().Add(presenceTTL).Unix())
+	pipe := rdb.Pipeline()
+	for _, id := range userIDs {
+		key := presenceKey(id)
+		pipe.ZAdd(ctx, key, &redis.Z{Score: expires, Member: node})
+		pipe.Expire(ctx, key, presenceTTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// MarkOffline снимает отметку: соединений пользователя с узлом node не осталось
+func MarkOffline(ctx context.Context, node, userID string) error {
+	if rdb == nil {
+		return nil
+	}
+	return rdb.ZRem(ctx, presenceKey(userID), node).Err()
+}
+
+// Online возвращает, кто из userIDs подключён хоть к одному узлу
+func Online(ctx context.Context, userIDs []string) (map[string]bool, error) {
+	out := make(map[string]bool, len(userIDs))
+	if rdb == nil || len(userIDs) == 0 {
+		return out, nil
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	pipe := rdb.Pipeline()
+	counts := make([]*redis.IntCmd, len(userIDs))
+	for i, id := range userIDs {
+		counts[i] = pipe.ZCount(ctx, presenceKey(id), "("+now, "+inf")
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	for i, id := range userIDs {
+		if counts[i].Val() > 0 {
+			out[id] = true
+		}
+	}
+	return out, nil
+}
+
+// KeepPresence раз в presenceTTL/3 продлевает отметки пользователей,
+// которых local возвращает как подключённых к узлу node
+func KeepPresence(node string, local func() []string) {
+	if rdb == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(presenceTTL / 3)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := MarkOnline(ctx, node, local()); err != nil {
+				log.Println("cluster: presence:", err)
+			}
+			cancel()
+		}
+	}()
+}
@@ -0,0 +1,43 @@
+// Перенос истории сообщений из messages в messages_by_bucket без остановки сервиса.
+//
+// Порядок:
+//  1. Перезапустить chat-service с MESSAGE_STORE=dual — новые сообщения и правки
+//     пишутся в обе таблицы, чтение пока из старой.
+//  2. Запустить эту команду. Её можно прервать и продолжить с -resume.
+//  3. Перезапустить chat-service с MESSAGE_STORE=bucketed.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"log"
+
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/repository"
+)
+
+func main() {
+	pageSize := flag.Int("page", 500, "rows per page")
+	resume := flag.String("resume", "", "page state printed by a previous run")
+	flag.Parse()
+
+	state, err := hex.DecodeString(*resume)
+	if err != nil {
+		log.Fatalf("bad -resume: %v", err)
+	}
+	if config.MessageStore != "dual" {
+		log.Printf("warning: MESSAGE_STORE=%s; chat-service must run with MESSAGE_STORE=dual during migration", config.MessageStore)
+	}
+
+	repository.InitCassandra()
+
+	total := 0
+	err = repository.CopyLegacyMessages(state, *pageSize, func(copied int, next []byte) {
+		total += copied
+		log.Printf("copied %d rows, resume: %s", total, hex.EncodeToString(next))
+	})
+	if err != nil {
+		log.Fatalf("migration failed after %d rows: %v", total, err)
+	}
+	log.Printf("done: %d rows copied", total)
+}
@@ -3,12 +3,71 @@ package config
 import (
     "log"
     "os"
+    "strconv"
+    "strings"
+    "time"
 )
 
 var (
     CassandraHost = mustGet("CASSANDRA_HOST")   // e.g. "cassandra:9042"
     CassandraKeyspace = mustGet("CASSANDRA_KEYSPACE") // "chats"
+    // Хранение истории: "dual", пока старая история не перенесена, затем "bucketed" (см. repository/buckets.go)
+    MessageStore  = getEnv("MESSAGE_STORE", "dual")
+    MessageBucket = getEnvDuration("MESSAGE_BUCKET", 10*24*time.Hour) // после запуска не менять
     JWTSecret   = mustGet("JWT_SECRET")
+    GuildServiceURL = getEnv("GUILD_SERVICE_URL", "http://guild-service:8080")
+    GuildCacheTTL   = getEnvDuration("GUILD_CACHE_TTL", 30*time.Second) // сколько помним членство в гильдии
+    ServiceToken    = os.Getenv("SERVICE_TOKEN") // общий секрет для внутренних вызовов от guild-service
+
+    // Вложения: где хранить файлы и как отдавать ссылки
+    StorageBackend   = getEnv("STORAGE_BACKEND", "fs") // "fs" или "s3"
+    StorageDir       = getEnv("STORAGE_DIR", "./data/attachments")
+    S3Endpoint       = os.Getenv("S3_ENDPOINT") // e.g. "minio:9000"
+    S3Bucket         = os.Getenv("S3_BUCKET")
+    S3AccessKey      = os.Getenv("S3_ACCESS_KEY")
+    S3SecretKey      = os.Getenv("S3_SECRET_KEY")
+    S3Region         = os.Getenv("S3_REGION")
+    S3UseSSL         = os.Getenv("S3_USE_SSL") == "true"
+    PublicURL        = os.Getenv("PUBLIC_URL") // префикс ссылок на вложения, пусто — относительные
+    MaxUploadBytes   = getEnvInt64("MAX_UPLOAD_BYTES", 8<<20) // лимит по умолчанию, гильдия может переопределить
+    AttachmentURLTTL = getEnvDuration("ATTACHMENT_URL_TTL", time.Hour)
+    ThumbnailSize    = int(getEnvInt64("THUMBNAIL_SIZE", 400)) // превью вписывается в квадрат, px
+    ThumbnailWorkers = int(getEnvInt64("THUMBNAIL_WORKERS", 2))
+
+    PinLimit = int(getEnvInt64("PIN_LIMIT", 50)) // максимум закреплённых сообщений в канале
+
+    NonceTTL = getEnvDuration("MESSAGE_NONCE_TTL", 10*time.Minute) // сколько помним nonce отправленного сообщения
+
+    // Лимиты отправки сообщений (token bucket): burst подряд, затем один токен раз в refill
+    RateUserBurst     = int(getEnvInt64("MESSAGE_RATE_USER_BURST", 5))
+    RateUserRefill    = getEnvDuration("MESSAGE_RATE_USER_REFILL", time.Second)
+    RateChannelBurst  = int(getEnvInt64("MESSAGE_RATE_CHANNEL_BURST", 50))
+    RateChannelRefill = getEnvDuration("MESSAGE_RATE_CHANNEL_REFILL", 100*time.Millisecond)
+
+    SearchIndexPath = getEnv("SEARCH_INDEX_PATH", "./data/search.bleve")
+
+    // WebSocket: очередь исходящих кадров на клиента и keepalive
+    WSSendBuffer    = int(getEnvInt64("WS_SEND_BUFFER", 256))
+    WSSlowPolicy    = getEnv("WS_SLOW_POLICY", "drop_oldest") // drop_oldest, disconnect или coalesce
+    WSWriteWait     = getEnvDuration("WS_WRITE_WAIT", 10*time.Second)
+    WSPongWait      = getEnvDuration("WS_PONG_WAIT", 60*time.Second) // ping уходит каждые 9/10 этого срока
+    WSMaxFrameBytes = getEnvInt64("WS_MAX_FRAME_BYTES", 64<<10)
+    // Подстраховка к сбросу прав из guild-service: так часто соединение перепроверяет свои подписки
+    WSRevalidateInterval = getEnvDuration("WS_REVALIDATE_INTERVAL", 10*time.Minute)
+
+    // Шина событий между репликами
+    BrokerBackend = getEnv("BROKER_BACKEND", "memory") // memory (одна реплика), redis или kafka
+    BrokerTopic   = getEnv("BROKER_TOPIC", "chat-fanout") // канал Redis или топик Kafka
+    BrokerBuffer  = int(getEnvInt64("BROKER_BUFFER", 4096)) // очередь кадров на отправку в шину; при переполнении кадры теряются
+    RedisAddr     = getEnv("REDIS_ADDR", "redis:6379")
+    // Общее состояние реплик (присутствие, блокировки фоновых задач): local (одна реплика) или redis
+    ClusterBackend = getEnv("CLUSTER_BACKEND", "local")
+    KafkaBrokers  = strings.Split(getEnv("KAFKA_BROKERS", "kafka:9092"), ",")
+
+    // События чата в Kafka для realtime-service (через outbox в Cassandra)
+    ChatEventsEnabled = getEnv("CHAT_EVENTS", "on") != "off"
+    ChatEventsTopic   = getEnv("CHAT_EVENTS_TOPIC", "chat")
+    OutboxInterval    = getEnvDuration("OUTBOX_INTERVAL", 5*time.Second) // как часто relay проверяет outbox
 )
 
 func mustGet(key string) string {
@@ -18,3 +77,34 @@ func mustGet(key string) string {
     }
     return v
 }
+
+func getEnv(key, def string) string {
+    if v := os.Getenv(key); v != "" {
+        return v
+    }
+    return def
+}
+
+func getEnvInt64(key string, def int64) int64 {
+    v := os.Getenv(key)
+    if v == "" {
+        return def
+    }
+    n, err := strconv.ParseInt(v, 10, 64)
+    if err != nil {
+        log.Fatalf("env %s: %v", key, err)
+    }
+    return n
+}
+
+func getEnvDuration(key string, def time.Duration) time.Duration {
+    v := os.Getenv(key)
+    if v == "" {
+        return def
+    }
+    d, err := time.ParseDuration(v)
+    if err != nil {
+        log.Fatalf("env %s: %v", key, err)
+    }
+    return d
+}
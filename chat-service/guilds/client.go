@@ -0,0 +1,149 @@
+package guilds
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/yourorg/chat-service/config"
+)
+
+// Клиент guild-service. Запросы идут с токеном пользователя,
+// поэтому guild-service сам проверяет, что ему можно видеть.
+
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+type Channel struct {
+	ID      string `json:"ID"`
+	GuildID string `json:"GuildID"`
+	Name    string `json:"Name"`
+	Type    string `json:"Type"`
+
+	SlowmodeSeconds int `json:"SlowmodeSeconds"`
+}
+
+type Guild struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	OwnerID string `json:"ownerId"`
+
+	MaxUploadBytes int64 `json:"maxUploadBytes"`
+}
+
+type Member struct {
+	GuildID string   `json:"guildId"`
+	UserID  string   `json:"userId"`
+	Roles   []string `json:"roles"`
+}
+
+// Ошибки guild-service: 404 и 403 (нет членства или прав)
+var (
+	ErrNotFound  = errors.New("guild-service: not found")
+	ErrForbidden = errors.New("guild-service: forbidden")
+)
+
+// Биты прав guild-service, которые нужны chat-service
+const (
+	PermAddReactions       int64 = 1 << 6
+	PermViewChannel        int64 = 1 << 10
+	PermSendMessages       int64 = 1 << 11
+	PermManageMessages     int64 = 1 << 13
+	PermAttachFiles        int64 = 1 << 15
+	PermReadMessageHistory int64 = 1 << 16
+	PermMentionEveryone    int64 = 1 << 17
+)
+
+func get(token, path string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, config.GuildServiceURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("guild-service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("guild-service: GET %s: status %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func GetChannel(token, channelID string) (*Channel, error) {
+	var ch Channel
+	if err := get(token, "/channels/"+url.PathEscape(channelID), &ch); err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+func GetGuild(token, guildID string) (*Guild, error) {
+	var g Guild
+	if err := get(token, "/guilds/"+url.PathEscape(guildID), &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// channelGuilds кэширует гильдию канала: каналы не переезжают между гильдиями
+var channelGuilds sync.Map
+
+// GuildIDForChannel возвращает ID гильдии канала, запоминая ответ
+func GuildIDForChannel(token, channelID string) (string, error) {
+	if id, ok := channelGuilds.Load(channelID); ok {
+		return id.(string), nil
+	}
+	ch, err := GetChannel(token, channelID)
+	if err != nil {
+		return "", err
+	}
+	channelGuilds.Store(channelID, ch.GuildID)
+	return ch.GuildID, nil
+}
+
+// GuildForChannel возвращает гильдию, которой принадлежит канал
+func GuildForChannel(token, channelID string) (*Guild, error) {
+	ch, err := GetChannel(token, channelID)
+	if err != nil {
+		return nil, err
+	}
+	return GetGuild(token, ch.GuildID)
+}
+
+func GetChannels(token, guildID string) ([]Channel, error) {
+	var list []Channel
+	if err := get(token, "/guilds/"+url.PathEscape(guildID)+"/channels", &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CanModerate сообщает, может ли пользователь управлять чужими сообщениями в канале:
+// нужно право MANAGE_MESSAGES в этом канале (у владельца и администраторов оно есть всегда).
+func CanModerate(token, channelID, userID string) (bool, error) {
+	perms, err := ChannelPermissions(token, channelID, userID)
+	if err != nil {
+		return false, err
+	}
+	return perms&PermManageMessages != 0, nil
+}
+
+// IsMember сообщает, состоит ли пользователь в гильдии (владелец считается участником)
+func IsMember(token string, g *Guild, userID string) (bool, error) {
+	if g.OwnerID == userID {
+		return true, nil
+	}
+	return CheckMember(token, g.ID, userID)
+}
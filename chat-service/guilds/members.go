@@ -0,0 +1,120 @@
+package guilds
+
+import (
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/yourorg/chat-service/config"
+)
+
+// Кэш членства в гильдиях: проверка идёт почти на каждый кадр и запрос,
+// поэтому guild-service спрашиваем не чаще раза в GUILD_CACHE_TTL.
+// Отказ помним в 5 раз меньше, чтобы только что вступивший быстро получил доступ.
+
+// maxCachedMembers — при таком размере кэша из него вычищаются устаревшие записи
+const maxCachedMembers = 10000
+
+type memberKey struct {
+	guildID string
+	userID  string
+}
+
+type memberEntry struct {
+	ok      bool
+	expires time.Time
+}
+
+var memberCache = struct {
+	sync.Mutex
+	m map[memberKey]memberEntry
+}{m: make(map[memberKey]memberEntry)}
+
+// GetMember возвращает участника гильдии; владелец — тоже участник
+func GetMember(token, guildID, userID string) (*Member, error) {
+	var m Member
+	path := "/guilds/" + url.PathEscape(guildID) + "/members/" + url.PathEscape(userID)
+	if err := get(token, path, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ChannelMember — участник, которому виден канал, с правами в нём
+type ChannelMember struct {
+	UserID      string   `json:"userId"`
+	Roles       []string `json:"roles"`
+	Permissions int64    `json:"permissions"`
+}
+
+// GetChannelMembers возвращает участников, которые видят канал; владелец гильдии тоже среди них
+func GetChannelMembers(token, channelID string) ([]ChannelMember, error) {
+	var list []ChannelMember
+	if err := get(token, "/channels/"+url.PathEscape(channelID)+"/members", &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CheckMember сообщает, состоит ли пользователь в гильдии. Ответ кэшируется.
+func CheckMember(token, guildID, userID string) (bool, error) {
+	key := memberKey{guildID, userID}
+	now := time.Now()
+	memberCache.Lock()
+	e, ok := memberCache.m[key]
+	memberCache.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.ok, nil
+	}
+
+	_, err := GetMember(token, guildID, userID)
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrForbidden) {
+		return false, err
+	}
+	e = memberEntry{ok: err == nil, expires: now.Add(config.GuildCacheTTL)}
+	if !e.ok {
+		e.expires = now.Add(config.GuildCacheTTL / 5)
+	}
+
+	memberCache.Lock()
+	defer memberCache.Unlock()
+	if len(memberCache.m) >= maxCachedMembers {
+		for k, old := range memberCache.m {
+			if !now.Before(old.expires) {
+				delete(memberCache.m, k)
+			}
+		}
+	}
+	memberCache.m[key] = e
+	return e.ok, nil
+}
+
+// ForgetChannel сбрасывает закэшированные гильдию и настройки канала
+func ForgetChannel(channelID string) {
+	channelGuilds.Delete(channelID)
+	channelSettings.Delete(channelID)
+}
+
+// channelSettings кэширует каналы на GUILD_CACHE_TTL: настройки вроде медленного режима
+// нужны на каждое сообщение, а меняются редко
+var channelSettings sync.Map
+
+type channelEntry struct {
+	ch      *Channel
+	expires time.Time
+}
+
+// CachedChannel возвращает канал, запоминая ответ на GUILD_CACHE_TTL
+func CachedChannel(token, channelID string) (*Channel, error) {
+	now := time.Now()
+	if e, ok := channelSettings.Load(channelID); ok && now.Before(e.(channelEntry).expires) {
+		return e.(channelEntry).ch, nil
+	}
+	ch, err := GetChannel(token, channelID)
+	if err != nil {
+		return nil, err
+	}
+	channelSettings.Store(channelID, channelEntry{ch: ch, expires: now.Add(config.GuildCacheTTL)})
+	return ch, nil
+}
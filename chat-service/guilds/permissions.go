@@ -0,0 +1,108 @@
+package guilds
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourorg/chat-service/config"
+)
+
+// Права в канале считает guild-service с учётом переопределений канала.
+// Кэшируются так же, как членство: на GUILD_CACHE_TTL, отказ — в 5 раз меньше.
+
+type permKey struct {
+	channelID string
+	userID    string
+}
+
+type permEntry struct {
+	perms   int64
+	expires time.Time
+}
+
+var permCache = struct {
+	sync.Mutex
+	m map[permKey]permEntry
+}{m: make(map[permKey]permEntry)}
+
+// GetChannelPermissions возвращает права владельца токена в канале; 0 — канал ему не виден
+func GetChannelPermissions(token, channelID string) (int64, error) {
+	var out struct {
+		Permissions int64 `json:"permissions"`
+	}
+	if err := get(token, "/channels/"+url.PathEscape(channelID)+"/permissions", &out); err != nil {
+		return 0, err
+	}
+	return out.Permissions, nil
+}
+
+// ChannelPermissions — то же с кэшем. Не участник гильдии получает 0.
+// Права спрашиваются с токеном самого пользователя, поэтому userID должен быть его владельцем.
+func ChannelPermissions(token, channelID, userID string) (int64, error) {
+	key := permKey{channelID, userID}
+	now := time.Now()
+	permCache.Lock()
+	e, ok := permCache.m[key]
+	permCache.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.perms, nil
+	}
+
+	perms, err := GetChannelPermissions(token, channelID)
+	if errors.Is(err, ErrForbidden) {
+		perms, err = 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	e = permEntry{perms: perms, expires: now.Add(config.GuildCacheTTL)}
+	if perms&PermViewChannel == 0 {
+		e.expires = now.Add(config.GuildCacheTTL / 5)
+	}
+
+	permCache.Lock()
+	defer permCache.Unlock()
+	if len(permCache.m) >= maxCachedMembers {
+		for k, old := range permCache.m {
+			if !now.Before(old.expires) {
+				delete(permCache.m, k)
+			}
+		}
+	}
+	permCache.m[key] = e
+	return perms, nil
+}
+
+// ForgetUsers сбрасывает всё закэшированное о пользователях: права во всех каналах и членство
+func ForgetUsers(userIDs []string) {
+	forget := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		forget[strings.ToLower(id)] = true
+	}
+
+	permCache.Lock()
+	for k := range permCache.m {
+		if forget[strings.ToLower(k.userID)] {
+			delete(permCache.m, k)
+		}
+	}
+	permCache.Unlock()
+
+	memberCache.Lock()
+	for k := range memberCache.m {
+		if forget[strings.ToLower(k.userID)] {
+			delete(memberCache.m, k)
+		}
+	}
+	memberCache.Unlock()
+}
+
+// ForgetChannelPermissions сбрасывает закэшированные права пользователя в канале
+func ForgetChannelPermissions(channelID, userID string) {
+	permCache.Lock()
+	defer permCache.Unlock()
+	delete(permCache.m, permKey{channelID, userID})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourorg/chat-service/storage"
+	"github.com/yourorg/chat-service/ws"
+)
+
+// Запас на заголовки multipart поверх самого файла
+const multipartOverhead = 1 << 20
+
+// POST /channels/:channelId/attachments (multipart, поле "file")
+func UploadAttachment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		channelID := c.Param("channelId")
+		limit, err := ws.UploadLimit(actor(c), channelID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+multipartOverhead)
+
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": ws.ErrFileTooLarge.Error(), "maxBytes": limit})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file required"})
+			return
+		}
+		defer file.Close()
+
+		u, err := ws.Upload(c.Request.Context(), actor(c), channelID, limit, file, header)
+		if err != nil {
+			if errors.Is(err, ws.ErrFileTooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error(), "maxBytes": limit})
+				return
+			}
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, u.Attachment)
+	}
+}
+
+// GET /channels/:channelId/attachments/:attachmentId/:filename?sig=[&variant=thumbnail]
+// Без JWT: доступ даёт подпись в ссылке.
+func DownloadAttachment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, r, err := ws.OpenAttachment(c.Request.Context(), c.Param("channelId"), c.Param("attachmentId"), c.Query("variant"), c.Query("sig"))
+		if err != nil {
+			if errors.Is(err, storage.ErrInvalidSignature) {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			writeError(c, err)
+			return
+		}
+		defer r.Close()
+
+		disposition := "attachment"
+		if strings.HasPrefix(u.ContentType, "image/") || strings.HasPrefix(u.ContentType, "video/") {
+			disposition = "inline"
+		}
+		c.DataFromReader(http.StatusOK, u.Size, u.ContentType, r, map[string]string{
+			"Content-Disposition":    mime.FormatMediaType(disposition, map[string]string{"filename": u.Filename}),
+			"Cache-Control":          "private, max-age=3600",
+			"X-Content-Type-Options": "nosniff",
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/ws"
+)
+
+// Курсоры для следующих страниц отдаются в заголовках,
+// чтобы тело ответа осталось массивом сообщений
+const (
+	headerCursorOlder = "X-Cursor-Older"
+	headerCursorNewer = "X-Cursor-Newer"
+)
+
+// GET /channels/:channelId/messages?limit=50&before=|after=|around=|cursor=
+func GetMessages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q := ws.HistoryQuery{
+			Before: c.Query("before"),
+			After:  c.Query("after"),
+			Around: c.Query("around"),
+			Cursor: c.Query("cursor"),
+		}
+		if s := c.Query("limit"); s != "" {
+			limit, err := strconv.Atoi(s)
+			if err != nil || limit <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+				return
+			}
+			q.Limit = limit
+		}
+
+		page, err := ws.History(actor(c), c.Param("channelId"), q)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		if page.Older != "" {
+			c.Header(headerCursorOlder, page.Older)
+		}
+		if page.Newer != "" {
+			c.Header(headerCursorNewer, page.Newer)
+		}
+		msgs := page.Messages
+		if msgs == nil {
+			msgs = []models.Message{}
+		}
+		c.JSON(http.StatusOK, msgs)
+	}
+}
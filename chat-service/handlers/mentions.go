@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+
+	"github.com/yourorg/chat-service/ws"
+)
+
+const maxMentionsPage = 100
+
+// GET /users/@me/mentions?limit=&before=<messageId>
+func GetMyMentions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := 25
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > maxMentionsPage {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+		var before *gocql.UUID
+		if v := c.Query("before"); v != "" {
+			id, err := gocql.ParseUUID(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before"})
+				return
+			}
+			before = &id
+		}
+
+		list, err := ws.GetMentions(actor(c), limit, before)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
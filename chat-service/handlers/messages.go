@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourorg/chat-service/guilds"
+	"github.com/yourorg/chat-service/repository"
+	"github.com/yourorg/chat-service/search"
+	"github.com/yourorg/chat-service/ws"
+)
+
+type createMessageInput struct {
+	Content     string   `json:"content"`
+	ReplyTo     string   `json:"replyTo"`
+	Attachments []string `json:"attachments"`
+	Nonce       string   `json:"nonce"`
+}
+
+type editMessageInput struct {
+	Content string `json:"content"`
+}
+
+func actor(c *gin.Context) ws.Actor {
+	return ws.Actor{UserID: c.GetString("userId"), Token: c.GetString("token")}
+}
+
+// writeError переводит ошибки операций над сообщениями в HTTP-ответ
+func writeError(c *gin.Context, err error) {
+	var limited *ws.RateLimitError
+	switch {
+	case errors.As(err, &limited):
+		writeRateLimited(c, limited)
+	case errors.Is(err, ws.ErrInvalidID), errors.Is(err, ws.ErrEmptyContent), errors.Is(err, ws.ErrInvalidEmoji),
+		errors.Is(err, ws.ErrInvalidReply), errors.Is(err, ws.ErrInvalidThread), errors.Is(err, ws.ErrNestedThread),
+		errors.Is(err, ws.ErrInvalidAttachment), errors.Is(err, ws.ErrTooManyAttachments), errors.Is(err, ws.ErrInvalidCursor),
+		errors.Is(err, ws.ErrInvalidNonce), errors.Is(err, search.ErrBadQuery):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrThreadExists), errors.Is(err, repository.ErrUploadUsed), errors.Is(err, ws.ErrPinLimit),
+		errors.Is(err, ws.ErrNonceConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ws.ErrForbidden), errors.Is(err, guilds.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, guilds.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// writeRateLimited отвечает 429: Retry-After в целых секундах,
+// X-RateLimit-Reset-After — точнее, с миллисекундами
+func writeRateLimited(c *gin.Context, e *ws.RateLimitError) {
+	retry := e.RetryAfter.Seconds()
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry))))
+	c.Header("X-RateLimit-Reset-After", strconv.FormatFloat(retry, 'f', 3, 64))
+	c.Header("X-RateLimit-Scope", e.Scope)
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limited", "scope": e.Scope, "retryAfter": retry})
+}
+
+// POST /channels/:channelId/messages
+// Повтор с тем же nonce отвечает 200 и исходным сообщением вместо 201.
+func CreateMessage(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in createMessageInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		m, created, err := ws.CreateMessage(hub, actor(c), ws.NewMessage{
+			ChannelID:   c.Param("channelId"),
+			Content:     in.Content,
+			ReplyTo:     in.ReplyTo,
+			Attachments: in.Attachments,
+			Nonce:       in.Nonce,
+		})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		if !created {
+			c.JSON(http.StatusOK, m)
+			return
+		}
+		c.JSON(http.StatusCreated, m)
+	}
+}
+
+// PATCH /channels/:channelId/messages/:messageId
+func EditMessage(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in editMessageInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		m, err := ws.EditMessage(hub, actor(c), c.Param("channelId"), c.Param("messageId"), in.Content)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, m)
+	}
+}
+
+// DELETE /channels/:channelId/messages/:messageId
+func DeleteMessage(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := ws.DeleteMessage(hub, actor(c), c.Param("channelId"), c.Param("messageId")); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// purgeInput — что чистить: сообщения за последние Seconds секунд (не дальше недели)
+// в каналах ChannelIDs и их ветках
+type purgeInput struct {
+	Seconds    int      `json:"seconds" binding:"required,min=1,max=604800"`
+	ChannelIDs []string `json:"channelIds"`
+}
+
+// POST /internal/guilds/:guildId/members/:userId/messages/purge
+// Вызывается guild-service при бане. BAN_MEMBERS он уже проверил и сам передаёт
+// текстовые каналы гильдии, поэтому права модератора в каналах здесь не нужны.
+func PurgeMessages(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in purgeInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		since := time.Now().Add(-time.Duration(in.Seconds) * time.Second)
+		n, err := ws.PurgeMessages(hub, c.Param("guildId"), c.Param("userId"), in.ChannelIDs, since)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"deleted": n})
+	}
+}
+
+// forgetInput — чьи права изменились
+type forgetInput struct {
+	UserIDs []string `json:"userIds" binding:"required,max=1000"`
+}
+
+// presenceInput — о ком спрашивают
+type presenceInput struct {
+	UserIDs []string `json:"userIds" binding:"required,max=1000"`
+}
+
+// POST /internal/presence
+// Кто из пользователей сейчас подключён к чату хотя бы на одной реплике.
+// guild-service так находит временных участников, которых пора убрать из гильдий.
+func GetPresence(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in presenceInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		online := make([]string, 0, len(in.UserIDs))
+		for id, ok := range hub.Online(in.UserIDs) {
+			if ok {
+				online = append(online, id)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"online": online})
+	}
+}
+
+// POST /internal/permissions/forget
+// Сбрасывает закэшированные права пользователей на всех репликах и перепроверяет их подписки
+func ForgetPermissions(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in forgetInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		hub.ForgetUsers(in.UserIDs)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// PUT /channels/:channelId/messages/:messageId/reactions/:emoji/@me
+func AddReaction(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := ws.AddReaction(hub, actor(c), c.Param("channelId"), c.Param("messageId"), c.Param("emoji")); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// DELETE /channels/:channelId/messages/:messageId/reactions/:emoji/@me
+func RemoveReaction(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := ws.RemoveReaction(hub, actor(c), c.Param("channelId"), c.Param("messageId"), c.Param("emoji")); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourorg/chat-service/ws"
+)
+
+// GET /channels/:channelId/pins
+func GetPins() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := ws.ListPins(actor(c), c.Param("channelId"))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// PUT /channels/:channelId/pins/:messageId
+func PinMessage(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := ws.PinMessage(hub, actor(c), c.Param("channelId"), c.Param("messageId")); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// DELETE /channels/:channelId/pins/:messageId
+func UnpinMessage(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := ws.UnpinMessage(hub, actor(c), c.Param("channelId"), c.Param("messageId")); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
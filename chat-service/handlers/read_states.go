@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourorg/chat-service/ws"
+)
+
+// POST /channels/:channelId/messages/:messageId/ack
+func AckMessage(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := ws.AckMessage(hub, actor(c), c.Param("channelId"), c.Param("messageId")); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GET /guilds/:guildId/read-states
+func GetGuildReadStates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := ws.GuildReadStates(actor(c), c.Param("guildId"))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourorg/chat-service/ws"
+)
+
+// GET /guilds/:guildId/messages/search?q=...&limit=25&offset=0
+func SearchMessages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		offset, _ := strconv.Atoi(c.Query("offset"))
+
+		res, err := ws.SearchMessages(actor(c), c.Param("guildId"), c.Query("q"), limit, offset)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, res)
+	}
+}
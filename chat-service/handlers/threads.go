@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourorg/chat-service/ws"
+)
+
+type createThreadInput struct {
+	Name                string `json:"name" binding:"required"`
+	AutoArchiveDuration int    `json:"autoArchiveDuration"` // минуты: 60, 1440, 4320 или 10080
+}
+
+// POST /channels/:channelId/messages/:messageId/threads
+func CreateThread(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in createThreadInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		t, err := ws.CreateThread(hub, actor(c), c.Param("channelId"), c.Param("messageId"), in.Name, in.AutoArchiveDuration)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, t)
+	}
+}
+
+// GET /channels/:channelId/threads?archived=true|false
+func GetThreads() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var archived *bool
+		if v := c.Query("archived"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid archived"})
+				return
+			}
+			archived = &b
+		}
+
+		list, err := ws.ListThreads(actor(c), c.Param("channelId"), archived)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// PATCH /channels/:channelId/threads/:threadId
+func UpdateThread(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var patch ws.ThreadPatch
+		if err := c.ShouldBindJSON(&patch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		t, err := ws.UpdateThread(hub, actor(c), c.Param("channelId"), c.Param("threadId"), patch)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, t)
+	}
+}
@@ -4,23 +4,44 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 
-	_ "github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/broker"
+	"github.com/yourorg/chat-service/cluster"
+	"github.com/yourorg/chat-service/config"
 	_ "github.com/yourorg/chat-service/middleware"
+	"github.com/yourorg/chat-service/outbox"
 	"github.com/yourorg/chat-service/repository"
 	"github.com/yourorg/chat-service/routes"
+	"github.com/yourorg/chat-service/search"
+	"github.com/yourorg/chat-service/storage"
 	"github.com/yourorg/chat-service/ws"
 )
 
 func main() {
 	// Инициализируем Cassandra
 	repository.InitCassandra()
+	// Хранилище вложений
+	storage.Init()
+	// Поисковый индекс сообщений
+	search.Init()
 
-	// Создаём хаб WS
-	hub := ws.NewHub()
+	// Общее состояние реплик: присутствие пользователей и блокировки фоновых задач
+	cluster.Init()
+
+	// Создаём хаб WS; события между репликами ходят через брокер
+	hub := ws.NewHub(broker.MustNew())
+	// Автоархивация неактивных веток (за период работает одна реплика)
+	go ws.RunThreadArchiver(hub, time.Minute)
+	// Фоновая генерация превью картинок
+	ws.StartThumbnailer(hub, config.ThumbnailWorkers)
+	// Отправка событий чата из outbox в Kafka
+	outbox.Start()
+	// Поисковый индекс читает те же события, чтобы видеть сообщения всех реплик
+	search.Follow()
 
 	// Запускаем Gin
 	r := gin.Default()
@@ -30,8 +51,9 @@ func main() {
 	}
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     strings.Split(originsEnv, ","),
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Authorization", "Content-Type"},
+		ExposeHeaders:    []string{"X-Cursor-Older", "X-Cursor-Newer", "Retry-After", "X-RateLimit-Reset-After", "X-RateLimit-Scope"},
 		AllowCredentials: true,
 	}))
 	// Зарегистрируем маршруты
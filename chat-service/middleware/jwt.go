@@ -51,6 +51,8 @@ func JWTAuth() gin.HandlerFunc {
 
         // 6. Сохраняем идентификатор пользователя в контекст
         c.Set("userId", sub)
+        // Токен нужен для запросов к guild-service от имени пользователя
+        c.Set("token", tokenStr)
         c.Next()
     }
 }
\ No newline at end of file
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourorg/chat-service/config"
+)
+
+// ServiceAuth пускает только вызовы других сервисов: в X-Service-Token должен быть
+// общий SERVICE_TOKEN. Если он не задан, внутренние маршруты закрыты.
+func ServiceAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader("X-Service-Token")
+		if config.ServiceToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(config.ServiceToken)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+    "time"
+
+    "github.com/gocql/gocql"
+)
+
+// Attachment — метаданные файла, приложенного к сообщению.
+// Теги cql соответствуют UDT attachment в Cassandra.
+type Attachment struct {
+    ID          gocql.UUID `cql:"id"           json:"id"`
+    Filename    string     `cql:"filename"     json:"filename"`
+    Size        int64      `cql:"size"         json:"size"`
+    ContentType string     `cql:"content_type" json:"contentType"`
+    Width       int        `cql:"width"        json:"width,omitempty"`
+    Height      int        `cql:"height"       json:"height,omitempty"`
+    ThumbnailKey string    `cql:"thumbnail_key" json:"-"` // ключ превью в хранилище, пусто — превью нет
+    URL          string    `json:"url"`                    // подписанные ссылки, не хранятся
+    ThumbnailURL string    `json:"thumbnailUrl,omitempty"`
+}
+
+// Upload — загруженный, но ещё (или уже) привязанный к сообщению файл
+type Upload struct {
+    Attachment
+    ChannelID  gocql.UUID
+    UploaderID string
+    Key        string      // ключ в хранилище
+    MessageID  *gocql.UUID // nil, пока файл не приложен к сообщению
+    CreatedAt  time.Time
+}
@@ -6,10 +6,46 @@ import (
     "github.com/gocql/gocql"
 )
 
+// Виды сообщений; обычное сообщение — пустая строка
+const (
+    MessageKindPinned = "CHANNEL_PINNED_MESSAGE" // системное: «X закрепил сообщение», replyTo — закреплённое
+)
+
 type Message struct {
     ChannelID   gocql.UUID `json:"channelId"`
     MessageID   gocql.UUID `json:"messageId"`
     SenderID    string     `json:"senderId"`
     Content     string     `json:"content"`
     CreatedAt   time.Time  `json:"createdAt"`
+    EditedAt    *time.Time `json:"editedAt,omitempty"`
+    Deleted     bool       `json:"deleted,omitempty"`
+    Reactions   []Reaction `json:"reactions,omitempty"`
+    ReplyTo     *gocql.UUID `json:"replyTo,omitempty"`  // сообщение, на которое это ответ
+    ThreadID    *gocql.UUID `json:"threadId,omitempty"` // ветка, начатая с этого сообщения
+    Mentions        []string `json:"mentions,omitempty"`     // упомянутые пользователи
+    MentionRoles    []string `json:"mentionRoles,omitempty"` // упомянутые роли
+    MentionEveryone bool     `json:"mentionEveryone,omitempty"` // @everyone или @here
+    Attachments     []Attachment `json:"attachments,omitempty"`
+    Kind            string       `json:"kind,omitempty"`
+    Nonce           string       `json:"nonce,omitempty"` // nonce клиента; не хранится, только эхо отправителю
+}
+
+// Pin — закреплённое сообщение канала
+type Pin struct {
+    PinnedBy string    `json:"pinnedBy"`
+    PinnedAt time.Time `json:"pinnedAt"`
+    Message
+}
+
+// Mention — запись во входящих упоминаниях пользователя
+type Mention struct {
+    GuildID string `json:"guildId"`
+    Message
+}
+
+// Reaction — агрегат реакций одним эмодзи на сообщение
+type Reaction struct {
+    Emoji string `json:"emoji"`
+    Count int    `json:"count"`
+    Me    bool   `json:"me"`
 }
@@ -0,0 +1,16 @@
+package models
+
+import (
+    "time"
+
+    "github.com/gocql/gocql"
+)
+
+// ReadState — докуда пользователь дочитал канал
+type ReadState struct {
+    ChannelID    gocql.UUID  `json:"channelId"`
+    LastReadID   *gocql.UUID `json:"lastReadId"`
+    LastReadAt   time.Time   `json:"-"` // created_at последнего прочитанного сообщения
+    UnreadCount  int         `json:"unreadCount"`
+    MentionCount int         `json:"mentionCount"`
+}
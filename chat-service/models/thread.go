@@ -0,0 +1,27 @@
+package models
+
+import (
+    "time"
+
+    "github.com/gocql/gocql"
+)
+
+// Thread — ветка обсуждения, привязанная к сообщению канала.
+// Сообщения ветки хранятся в messages в партиции с channel_id = ThreadID.
+type Thread struct {
+    ThreadID           gocql.UUID `json:"threadId"`
+    ChannelID          gocql.UUID `json:"channelId"`
+    ParentMessageID    gocql.UUID `json:"parentMessageId"`
+    Name               string     `json:"name"`
+    CreatorID          string     `json:"creatorId"`
+    CreatedAt          time.Time  `json:"createdAt"`
+    LastMessageAt      time.Time  `json:"lastMessageAt"`
+    AutoArchiveMinutes int        `json:"autoArchiveMinutes"`
+    Archived           bool       `json:"archived"`
+    ArchivedAt         *time.Time `json:"archivedAt,omitempty"`
+}
+
+// Inactive сообщает, истёк ли срок автоархивации к моменту now
+func (t *Thread) Inactive(now time.Time) bool {
+    return now.Sub(t.LastMessageAt) >= time.Duration(t.AutoArchiveMinutes)*time.Minute
+}
@@ -0,0 +1,170 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/gocql/gocql"
+
+	"github.com/yourorg/chat-service/cluster"
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/repository"
+)
+
+// События чата для внешних потребителей (realtime-service) через Kafka.
+// Событие сначала пишется в outbox в Cassandra, затем фоновый relay
+// отправляет его в топик и удаляет из outbox. Пока Kafka недоступна,
+// события копятся и уходят после восстановления.
+// Relay запущен на каждой реплике, но outbox разбирает только взявшая блокировку.
+// Доставка at-least-once: событие уйдёт повторно, если реплика упала между
+// отправкой в Kafka и удалением из outbox.
+
+// Version — версия формата Event
+const Version = 1
+
+// Event — конверт события в топике. Ключ сообщения Kafka — guildId.
+type Event struct {
+	Version   int             `json:"version"`
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	GuildID   string          `json:"guildId"`
+	ChannelID string          `json:"channelId"`
+	Payload   json.RawMessage `json:"payload"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+const relayBatch = 500
+
+// relayLease — на сколько реплика занимает outbox; flush укладывается в этот срок
+const relayLease = 30 * time.Second
+
+// wake будит relay сразу после Emit, не дожидаясь тика
+var wake = make(chan struct{}, 1)
+
+// Emit кладёт событие в outbox
+func Emit(typ, guildID, channelID string, payload interface{}) error {
+	if !config.ChatEventsEnabled {
+		return nil
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	id := gocql.TimeUUID()
+	data, err := json.Marshal(Event{
+		Version:   Version,
+		ID:        id.String(),
+		Type:      typ,
+		GuildID:   guildID,
+		ChannelID: channelID,
+		Payload:   raw,
+		Timestamp: id.Time(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := repository.SaveOutbox(repository.OutboxEntry{ID: id, Key: guildID, Payload: data}); err != nil {
+		return err
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Start запускает relay outbox -> Kafka
+func Start() {
+	if !config.ChatEventsEnabled {
+		return
+	}
+	go relay()
+}
+
+func relay() {
+	ticker := time.NewTicker(config.OutboxInterval)
+	defer ticker.Stop()
+
+	var producer sarama.SyncProducer
+	for {
+		select {
+		case <-ticker.C:
+		case <-wake:
+		}
+
+		if producer == nil {
+			p, err := newProducer()
+			if err != nil {
+				log.Println("outbox: kafka unavailable:", err)
+				continue
+			}
+			producer = p
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ok, err := cluster.TryLock(ctx, "outbox-relay", relayLease)
+		cancel()
+		if err != nil {
+			log.Println("outbox: lock:", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		// Останавливаемся с запасом, чтобы блокировка не истекла посреди отправки
+		err = flush(producer, time.Now().Add(relayLease*2/3))
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		if err := cluster.Unlock(ctx, "outbox-relay"); err != nil {
+			log.Println("outbox: unlock:", err)
+		}
+		cancel()
+		if err != nil {
+			log.Println("outbox: flush:", err)
+			// Переподключимся на следующем тике
+			producer.Close()
+			producer = nil
+		}
+	}
+}
+
+func newProducer() (sarama.SyncProducer, error) {
+	cfg := sarama.NewConfig()
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Partitioner = sarama.NewHashPartitioner
+	return sarama.NewSyncProducer(config.KafkaBrokers, cfg)
+}
+
+// flush отправляет накопившиеся события по порядку до deadline; на первой ошибке останавливается
+func flush(producer sarama.SyncProducer, deadline time.Time) error {
+	for time.Now().Before(deadline) {
+		pending, err := repository.PendingOutbox(relayBatch)
+		if err != nil {
+			return err
+		}
+		for _, e := range pending {
+			if !time.Now().Before(deadline) {
+				return nil
+			}
+			_, _, err := producer.SendMessage(&sarama.ProducerMessage{
+				Topic: config.ChatEventsTopic,
+				Key:   sarama.StringEncoder(e.Key),
+				Value: sarama.ByteEncoder(e.Payload),
+			})
+			if err != nil {
+				return err
+			}
+			if err := repository.DeleteOutbox(e.ID); err != nil {
+				return err
+			}
+		}
+		if len(pending) < relayBatch {
+			return nil
+		}
+	}
+	// Остаток отправим на следующем тике
+	return nil
+}
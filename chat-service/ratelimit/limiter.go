@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter — набор token bucket по ключам. В каждом bucket не больше burst токенов,
+// новый токен появляется раз в refill. Состояние живёт в памяти реплики.
+type Limiter struct {
+	mu      sync.Mutex
+	burst   float64
+	refill  time.Duration
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	at     time.Time // когда tokens были посчитаны
+}
+
+// maxBuckets — при таком числе ключей полные bucket выбрасываются: они не отличаются от новых
+const maxBuckets = 10000
+
+func New(burst int, refill time.Duration) *Limiter {
+	return &Limiter{
+		burst:   float64(burst),
+		refill:  refill,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow забирает токен для key. Если токенов нет, ok=false,
+// а retryAfter — через сколько появится следующий.
+func (l *Limiter) Allow(key string) (ok bool, retryAfter time.Duration) {
+	if l.burst <= 0 || l.refill <= 0 {
+		return true, 0
+	}
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, found := l.buckets[key]
+	if !found {
+		if len(l.buckets) >= maxBuckets {
+			l.sweep(now)
+		}
+		b = &bucket{tokens: l.burst, at: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.tokens(b, now)
+	b.at = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(l.refill))
+	}
+	b.tokens--
+	return true, 0
+}
+
+func (l *Limiter) tokens(b *bucket, now time.Time) float64 {
+	t := b.tokens + float64(now.Sub(b.at))/float64(l.refill)
+	if t > l.burst {
+		return l.burst
+	}
+	return t
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if l.tokens(b, now) >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
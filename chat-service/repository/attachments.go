@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gocql/gocql"
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/storage"
+)
+
+// ErrUploadUsed — файл уже приложен к другому сообщению
+var ErrUploadUsed = errors.New("attachment already used")
+
+const uploadColumns = `attachment_id, channel_id, uploader_id, blob_key, filename, size,
+        content_type, width, height, message_id, created_at, thumbnail_key`
+
+func uploadDest(u *models.Upload) []interface{} {
+	return []interface{}{
+		&u.ID, &u.ChannelID, &u.UploaderID, &u.Key, &u.Filename, &u.Size,
+		&u.ContentType, &u.Width, &u.Height, &u.MessageID, &u.CreatedAt, &u.ThumbnailKey,
+	}
+}
+
+func SaveUpload(u *models.Upload) error {
+	cql := fmt.Sprintf(`INSERT INTO %s.attachments (%s)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, config.CassandraKeyspace, uploadColumns)
+	if err := Session.Query(cql,
+		u.ID, u.ChannelID, u.UploaderID, u.Key, u.Filename, u.Size,
+		u.ContentType, u.Width, u.Height, u.MessageID, u.CreatedAt, u.ThumbnailKey,
+	).Exec(); err != nil {
+		return err
+	}
+	signAttachment(u.ChannelID, &u.Attachment)
+	return nil
+}
+
+func GetUpload(id gocql.UUID) (*models.Upload, error) {
+	var u models.Upload
+	err := Session.Query(fmt.Sprintf(`SELECT %s FROM %s.attachments WHERE attachment_id = ?`, uploadColumns, config.CassandraKeyspace),
+		id,
+	).Scan(uploadDest(&u)...)
+	if err == gocql.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	signAttachment(u.ChannelID, &u.Attachment)
+	return &u, nil
+}
+
+// LinkUpload закрепляет файл за сообщением; один файл — одно сообщение
+func LinkUpload(u *models.Upload, messageID gocql.UUID) error {
+	cql := fmt.Sprintf(`UPDATE %s.attachments SET message_id = ?
+        WHERE attachment_id = ? IF message_id = null`, config.CassandraKeyspace)
+	applied, err := Session.Query(cql, messageID, u.ID).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return ErrUploadUsed
+	}
+	u.MessageID = &messageID
+	return nil
+}
+
+// UnlinkUpload освобождает файл, закреплённый за так и не сохранённым сообщением messageID
+func UnlinkUpload(attachmentID, messageID gocql.UUID) error {
+	cql := fmt.Sprintf(`UPDATE %s.attachments SET message_id = null
+        WHERE attachment_id = ? IF message_id = ?`, config.CassandraKeyspace)
+	_, err := Session.Query(cql, attachmentID, messageID).MapScanCAS(map[string]interface{}{})
+	return err
+}
+
+// SetUploadPreview сохраняет размеры картинки и ключ превью
+func SetUploadPreview(u *models.Upload) error {
+	cql := fmt.Sprintf(`UPDATE %s.attachments SET width = ?, height = ?, thumbnail_key = ?
+        WHERE attachment_id = ?`, config.CassandraKeyspace)
+	return Session.Query(cql, u.Width, u.Height, u.ThumbnailKey, u.ID).Exec()
+}
+
+func DeleteUpload(id gocql.UUID) error {
+	return Session.Query(fmt.Sprintf(`DELETE FROM %s.attachments WHERE attachment_id = ?`, config.CassandraKeyspace), id).Exec()
+}
+
+// signAttachments проставляет вложениям сообщения свежие подписанные ссылки
+func signAttachments(m *models.Message) {
+	for i := range m.Attachments {
+		signAttachment(m.ChannelID, &m.Attachments[i])
+	}
+}
+
+func signAttachment(channelID gocql.UUID, a *models.Attachment) {
+	a.URL = storage.SignURL(channelID.String(), a.ID.String(), a.Filename)
+	a.ThumbnailURL = ""
+	if a.ThumbnailKey != "" {
+		a.ThumbnailURL = a.URL + "&variant=" + storage.VariantThumbnail
+	}
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+)
+
+// Сообщения хранятся в messages_by_bucket с ключом ((channel_id, bucket), created_at, message_id):
+// bucket — номер интервала config.MessageBucket от начала эпохи, так что партиция
+// канала не растёт бесконечно. channel_buckets перечисляет непустые бакеты канала.
+//
+// Старая таблица messages (партиция на весь канал) нужна только на время
+// миграции. Режим config.MessageStore:
+//   - "bucketed" — только новая таблица;
+//   - "dual"     — пишем в обе, читаем старую, пока cmd/migrate-buckets
+//     переносит историю (по умолчанию: после обновления история не пропадает).
+//     После миграции переключаемся на "bucketed".
+
+// msgTable — таблица сообщений и способ адресовать в ней строку
+type msgTable struct {
+	name     string
+	bucketed bool
+	ascOrder string // ORDER BY для обхода от старых к новым (обратный порядку кластеризации)
+}
+
+var (
+	legacyMessages = msgTable{name: "messages", ascOrder: "created_at ASC, message_id DESC"}
+	bucketMessages = msgTable{name: "messages_by_bucket", bucketed: true, ascOrder: "created_at ASC, message_id ASC"}
+)
+
+// Bucket возвращает номер бакета для момента t
+func Bucket(t time.Time) int64 {
+	return t.UnixMilli() / config.MessageBucket.Milliseconds()
+}
+
+func dualWrite() bool {
+	return config.MessageStore == "dual"
+}
+
+// readTable — таблица, из которой читаем сообщения
+func readTable() msgTable {
+	if dualWrite() {
+		return legacyMessages
+	}
+	return bucketMessages
+}
+
+// writeTables — таблицы, в которые пишем сообщения
+func writeTables() []msgTable {
+	if dualWrite() {
+		return []msgTable{legacyMessages, bucketMessages}
+	}
+	return []msgTable{bucketMessages}
+}
+
+// rowKey — условие WHERE на одну строку сообщения и его аргументы
+func (t msgTable) rowKey(channelID gocql.UUID, createdAt time.Time, messageID gocql.UUID) (string, []interface{}) {
+	if t.bucketed {
+		return "channel_id = ? AND bucket = ? AND created_at = ? AND message_id = ?",
+			[]interface{}{channelID, Bucket(createdAt), createdAt, messageID}
+	}
+	return "channel_id = ? AND created_at = ? AND message_id = ?",
+		[]interface{}{channelID, createdAt, messageID}
+}
+
+// updateMessage применяет SET ко всем таблицам сообщений
+func updateMessage(m *models.Message, set string, args ...interface{}) error {
+	tables := writeTables()
+	b := Session.NewBatch(gocql.LoggedBatch)
+	for _, t := range tables {
+		where, key := t.rowKey(m.ChannelID, m.CreatedAt, m.MessageID)
+		b.Query(fmt.Sprintf(`UPDATE %s.%s SET %s WHERE %s`, config.CassandraKeyspace, t.name, set, where),
+			append(append([]interface{}{}, args...), key...)...)
+	}
+	if len(tables) == 1 {
+		b.Type = gocql.UnloggedBatch
+	}
+	return Session.ExecuteBatch(b)
+}
+
+// casMessage применяет SET ... IF cond к таблице чтения (она источник истины),
+// а при успехе повторяет SET в остальных таблицах
+func casMessage(m *models.Message, set, cond string, args ...interface{}) (bool, error) {
+	rt := readTable()
+	where, key := rt.rowKey(m.ChannelID, m.CreatedAt, m.MessageID)
+	applied, err := Session.Query(fmt.Sprintf(`UPDATE %s.%s SET %s WHERE %s IF %s`,
+		config.CassandraKeyspace, rt.name, set, where, cond),
+		append(append([]interface{}{}, args...), key...)...,
+	).MapScanCAS(map[string]interface{}{})
+	if err != nil || !applied {
+		return applied, err
+	}
+	for _, t := range writeTables() {
+		if t == rt {
+			continue
+		}
+		where, key := t.rowKey(m.ChannelID, m.CreatedAt, m.MessageID)
+		if err := Session.Query(fmt.Sprintf(`UPDATE %s.%s SET %s WHERE %s`, config.CassandraKeyspace, t.name, set, where),
+			append(append([]interface{}{}, args...), key...)...,
+		).Exec(); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// channelBuckets возвращает непустые бакеты канала: от from назад (desc)
+// или от from вперёд; from == nil — все
+func channelBuckets(channelID gocql.UUID, from *int64, desc bool) ([]int64, error) {
+	cql := fmt.Sprintf(`SELECT bucket FROM %s.channel_buckets WHERE channel_id = ?`, config.CassandraKeyspace)
+	args := []interface{}{channelID}
+	if from != nil {
+		if desc {
+			cql += " AND bucket <= ?"
+		} else {
+			cql += " AND bucket >= ?"
+		}
+		args = append(args, *from)
+	}
+	if !desc {
+		cql += " ORDER BY bucket ASC"
+	}
+
+	iter := Session.Query(cql, args...).Iter()
+	var (
+		list []int64
+		b    int64
+	)
+	for iter.Scan(&b) {
+		list = append(list, b)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -13,6 +14,9 @@ import (
 
 var Session *gocql.Session
 
+// ErrNotFound возвращается, когда запрошенной записи нет
+var ErrNotFound = errors.New("not found")
+
 func InitCassandra() {
 	// Разбираем строку хостов (можно передать "cassandra1,cassandra2")
 	hosts := strings.Split(config.CassandraHost, ",")
@@ -80,47 +84,347 @@ func InitCassandra() {
 		log.Fatalf("Не удалось создать таблицу messages: %v", err)
 	}
 
+	// 5) Колонки для редактирования и мягкого удаления
+	ensureColumn("messages", "edited_at", "timestamp")
+	ensureColumn("messages", "deleted", "boolean")
+
+	ensureColumn("messages", "reply_to", "uuid")
+	ensureColumn("messages", "thread_id", "uuid")
+	ensureColumn("messages", "mentions", "set<text>")
+	ensureColumn("messages", "mention_roles", "set<text>")
+	ensureColumn("messages", "mention_everyone", "boolean")
+
+	cqlType := fmt.Sprintf(`
+        CREATE TYPE IF NOT EXISTS %s.attachment (
+            id uuid,
+            filename text,
+            size bigint,
+            content_type text,
+            width int,
+            height int
+        );
+    `, config.CassandraKeyspace)
+	if err := Session.Query(cqlType).Exec(); err != nil {
+		log.Fatalf("Не удалось создать тип attachment: %v", err)
+	}
+	ensureTypeField("attachment", "thumbnail_key", "text")
+	ensureColumn("messages", "attachments", "list<frozen<attachment>>")
+	ensureColumn("messages", "kind", "text")
+
+	// Новая схема: партиции по каналу и временному бакету
+	createTable("messages_by_bucket", `
+        CREATE TABLE IF NOT EXISTS %s.messages_by_bucket (
+            channel_id uuid,
+            bucket bigint,
+            created_at timestamp,
+            message_id uuid,
+            sender_id text,
+            content text,
+            edited_at timestamp,
+            deleted boolean,
+            reply_to uuid,
+            thread_id uuid,
+            mentions set<text>,
+            mention_roles set<text>,
+            mention_everyone boolean,
+            attachments list<frozen<attachment>>,
+            kind text,
+            PRIMARY KEY ((channel_id, bucket), created_at, message_id)
+        ) WITH CLUSTERING ORDER BY (created_at DESC, message_id DESC);
+    `)
+	createTable("channel_buckets", `
+        CREATE TABLE IF NOT EXISTS %s.channel_buckets (
+            channel_id uuid,
+            bucket bigint,
+            PRIMARY KEY ((channel_id), bucket)
+        ) WITH CLUSTERING ORDER BY (bucket DESC);
+    `)
+
+	// 6) Индекс message_id -> позиция в партиции, чтобы находить сообщение по ID
+	cqlIdx := fmt.Sprintf(`
+        CREATE TABLE IF NOT EXISTS %s.messages_by_id (
+            message_id uuid PRIMARY KEY,
+            channel_id uuid,
+            created_at timestamp
+        );
+    `, config.CassandraKeyspace)
+	if err := Session.Query(cqlIdx).Exec(); err != nil {
+		log.Fatalf("Не удалось создать таблицу messages_by_id: %v", err)
+	}
+
+	// 7) Остальные таблицы
+	createTable("threads", `
+        CREATE TABLE IF NOT EXISTS %s.threads (
+            channel_id uuid,
+            thread_id uuid,
+            parent_message_id uuid,
+            name text,
+            creator_id text,
+            created_at timestamp,
+            last_message_at timestamp,
+            auto_archive_minutes int,
+            archived boolean,
+            archived_at timestamp,
+            PRIMARY KEY ((channel_id), thread_id)
+        ) WITH CLUSTERING ORDER BY (thread_id DESC);
+    `)
+	createTable("threads_by_id", `
+        CREATE TABLE IF NOT EXISTS %s.threads_by_id (
+            thread_id uuid PRIMARY KEY,
+            channel_id uuid
+        );
+    `)
+	// Входящие упоминания живут 30 дней
+	createTable("user_mentions", `
+        CREATE TABLE IF NOT EXISTS %s.user_mentions (
+            user_id text,
+            message_id timeuuid,
+            channel_id uuid,
+            guild_id text,
+            PRIMARY KEY ((user_id), message_id)
+        ) WITH CLUSTERING ORDER BY (message_id DESC)
+          AND default_time_to_live = 2592000;
+    `)
+	// Outbox событий для Kafka: одна партиция-очередь, строки удаляются
+	// после отправки. Короткий gc_grace не даёт копиться надгробиям.
+	createTable("outbox", `
+        CREATE TABLE IF NOT EXISTS %s.outbox (
+            shard int,
+            event_id timeuuid,
+            event_key text,
+            payload blob,
+            PRIMARY KEY ((shard), event_id)
+        ) WITH gc_grace_seconds = 3600
+          AND default_time_to_live = 604800;
+    `)
+	// Nonce отправленных сообщений: повтор отправки после переподключения
+	// возвращает исходное сообщение. Строки живут MESSAGE_NONCE_TTL.
+	createTable("message_nonces", `
+        CREATE TABLE IF NOT EXISTS %s.message_nonces (
+            user_id text,
+            nonce text,
+            channel_id uuid,
+            message_id timeuuid,
+            PRIMARY KEY ((user_id, nonce))
+        ) WITH gc_grace_seconds = 3600;
+    `)
+	// Медленный режим: строка живёт, пока участнику нельзя снова писать в канал
+	createTable("slowmode", `
+        CREATE TABLE IF NOT EXISTS %s.slowmode (
+            channel_id uuid,
+            user_id text,
+            sent_at timestamp,
+            PRIMARY KEY ((channel_id, user_id))
+        ) WITH gc_grace_seconds = 3600;
+    `)
+	createTable("read_states", `
+        CREATE TABLE IF NOT EXISTS %s.read_states (
+            user_id text,
+            channel_id uuid,
+            last_read_id uuid,
+            last_read_at timestamp,
+            PRIMARY KEY ((user_id), channel_id)
+        );
+    `)
+	createTable("attachments", `
+        CREATE TABLE IF NOT EXISTS %s.attachments (
+            attachment_id uuid PRIMARY KEY,
+            channel_id uuid,
+            uploader_id text,
+            blob_key text,
+            filename text,
+            size bigint,
+            content_type text,
+            width int,
+            height int,
+            message_id uuid,
+            created_at timestamp
+        );
+    `)
+	ensureColumn("attachments", "thumbnail_key", "text")
+	createTable("pins", `
+        CREATE TABLE IF NOT EXISTS %s.pins (
+            channel_id uuid,
+            message_id uuid,
+            pinned_by text,
+            pinned_at timestamp,
+            PRIMARY KEY ((channel_id), message_id)
+        );
+    `)
+	createTable("reactions", `
+        CREATE TABLE IF NOT EXISTS %s.reactions (
+            channel_id uuid,
+            message_id uuid,
+            emoji text,
+            user_id text,
+            created_at timestamp,
+            PRIMARY KEY ((channel_id), message_id, emoji, user_id)
+        );
+    `)
+
 	log.Println("Cassandra инициализирована: keyspace и таблица готовы")
 }
 
-func SaveMessage(m *models.Message) error {
-	cql := fmt.Sprintf(`INSERT INTO %s.messages
-        (channel_id, created_at, message_id, sender_id, content)
-        VALUES (?, ?, ?, ?, ?)`, config.CassandraKeyspace)
-	return Session.Query(cql,
-		m.ChannelID, m.CreatedAt, m.MessageID, m.SenderID, m.Content,
-	).Exec()
+// createTable выполняет CREATE TABLE; %s в запросе заменяется на keyspace
+func createTable(name, cqlTpl string) {
+	if err := Session.Query(fmt.Sprintf(cqlTpl, config.CassandraKeyspace)).Exec(); err != nil {
+		log.Fatalf("Не удалось создать таблицу %s: %v", name, err)
+	}
 }
 
-func GetMessages(channelID string, limit int, after time.Time) ([]models.Message, error) {
-	var msgs []models.Message
-
-	cid, err := gocql.ParseUUID(channelID)
+// ensureTypeField добавляет поле в UDT, если его ещё нет
+func ensureTypeField(typeName, field, typ string) {
+	var fields []string
+	err := Session.Query(`SELECT field_names FROM system_schema.types
+        WHERE keyspace_name = ? AND type_name = ?`,
+		config.CassandraKeyspace, typeName).Scan(&fields)
 	if err != nil {
-		return nil, fmt.Errorf("invalid channelID: %w", err)
+		log.Fatalf("Не удалось прочитать тип %s: %v", typeName, err)
+	}
+	for _, f := range fields {
+		if f == field {
+			return
+		}
 	}
+	cql := fmt.Sprintf(`ALTER TYPE %s.%s ADD %s %s`, config.CassandraKeyspace, typeName, field, typ)
+	if err := Session.Query(cql).Exec(); err != nil {
+		log.Fatalf("Не удалось добавить поле %s.%s: %v", typeName, field, err)
+	}
+}
 
-	// Собираем CQL и аргументы
-	cql := fmt.Sprintf(`SELECT channel_id, created_at, message_id, sender_id, content 
-        FROM %s.messages WHERE channel_id = ?`, config.CassandraKeyspace)
-	args := []interface{}{cid}
-	if !after.IsZero() {
-		cql += " AND created_at < ?"
-		args = append(args, after)
+// ensureColumn добавляет колонку в таблицу, если её ещё нет
+func ensureColumn(table, column, typ string) {
+	var name string
+	err := Session.Query(`SELECT column_name FROM system_schema.columns
+        WHERE keyspace_name = ? AND table_name = ? AND column_name = ?`,
+		config.CassandraKeyspace, table, column).Scan(&name)
+	if err == nil {
+		return
 	}
+	if err != gocql.ErrNotFound {
+		log.Fatalf("Не удалось проверить колонку %s.%s: %v", table, column, err)
+	}
+	cql := fmt.Sprintf(`ALTER TABLE %s.%s ADD %s %s`, config.CassandraKeyspace, table, column, typ)
+	if err := Session.Query(cql).Exec(); err != nil {
+		log.Fatalf("Не удалось добавить колонку %s.%s: %v", table, column, err)
+	}
+}
+
+// messageColumns — порядок колонок, совпадающий с messageDest
+const messageColumns = `channel_id, created_at, message_id, sender_id, content, edited_at, deleted, reply_to, thread_id,
+        mentions, mention_roles, mention_everyone, attachments, kind`
 
-	q := Session.Query(cql, args...)
-	if limit > 0 {
-		q = q.PageSize(limit)
+func messageDest(m *models.Message) []interface{} {
+	return []interface{}{
+		&m.ChannelID, &m.CreatedAt, &m.MessageID, &m.SenderID, &m.Content,
+		&m.EditedAt, &m.Deleted, &m.ReplyTo, &m.ThreadID,
+		&m.Mentions, &m.MentionRoles, &m.MentionEveryone, &m.Attachments, &m.Kind,
 	}
+}
 
-	iter := q.Iter()
-	var m models.Message
-	for iter.Scan(&m.ChannelID, &m.CreatedAt, &m.MessageID, &m.SenderID, &m.Content) {
-		msgs = append(msgs, m)
+func SaveMessage(m *models.Message) error {
+	b := Session.NewBatch(gocql.LoggedBatch)
+	for _, t := range writeTables() {
+		cols, marks := "channel_id, ", "?, "
+		args := []interface{}{m.ChannelID}
+		if t.bucketed {
+			cols, marks = cols+"bucket, ", marks+"?, "
+			args = append(args, Bucket(m.CreatedAt))
+		}
+		b.Query(fmt.Sprintf(`INSERT INTO %s.%s
+            (%screated_at, message_id, sender_id, content, reply_to,
+             mentions, mention_roles, mention_everyone, attachments, kind)
+            VALUES (%s?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, config.CassandraKeyspace, t.name, cols, marks),
+			append(args,
+				m.CreatedAt, m.MessageID, m.SenderID, m.Content, m.ReplyTo,
+				m.Mentions, m.MentionRoles, m.MentionEveryone, m.Attachments, m.Kind,
+			)...,
+		)
 	}
-	if err := iter.Close(); err != nil {
+	b.Query(fmt.Sprintf(`INSERT INTO %s.channel_buckets (channel_id, bucket) VALUES (?, ?)`, config.CassandraKeyspace),
+		m.ChannelID, Bucket(m.CreatedAt),
+	)
+	b.Query(fmt.Sprintf(`INSERT INTO %s.messages_by_id
+        (message_id, channel_id, created_at) VALUES (?, ?, ?)`, config.CassandraKeyspace),
+		m.MessageID, m.ChannelID, m.CreatedAt,
+	)
+	return Session.ExecuteBatch(b)
+}
+
+// GetMessage ищет сообщение канала по его ID (включая удалённые)
+func GetMessage(channelID, messageID gocql.UUID) (*models.Message, error) {
+	var idxChannel gocql.UUID
+	var createdAt time.Time
+	err := Session.Query(fmt.Sprintf(`SELECT channel_id, created_at
+        FROM %s.messages_by_id WHERE message_id = ?`, config.CassandraKeyspace),
+		messageID,
+	).Scan(&idxChannel, &createdAt)
+
+	t := readTable()
+	var q *gocql.Query
+	switch {
+	case err == nil:
+		if idxChannel != channelID {
+			return nil, ErrNotFound
+		}
+		where, key := t.rowKey(channelID, createdAt, messageID)
+		q = Session.Query(fmt.Sprintf(`SELECT %s FROM %s.%s WHERE %s`,
+			messageColumns, config.CassandraKeyspace, t.name, where), key...)
+	case err == gocql.ErrNotFound:
+		// Сообщения, сохранённые до появления messages_by_id, ищем внутри партиции канала;
+		// бакет восстанавливаем по времени из timeuuid
+		where, key := "channel_id = ?", []interface{}{channelID}
+		if t.bucketed {
+			where, key = "channel_id = ? AND bucket = ?", []interface{}{channelID, Bucket(messageID.Time())}
+		}
+		q = Session.Query(fmt.Sprintf(`SELECT %s FROM %s.%s WHERE %s AND message_id = ? ALLOW FILTERING`,
+			messageColumns, config.CassandraKeyspace, t.name, where), append(key, messageID)...)
+	default:
+		return nil, err
+	}
+
+	var m models.Message
+	if err := q.Scan(messageDest(&m)...); err != nil {
+		if err == gocql.ErrNotFound {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
-	return msgs, nil
+	signAttachments(&m)
+	return &m, nil
+}
+
+// UpdateMessageContent сохраняет новый текст (и упоминания) сообщения m и проставляет edited_at
+func UpdateMessageContent(m *models.Message, editedAt time.Time) error {
+	if err := updateMessage(m, `content = ?, edited_at = ?, mentions = ?, mention_roles = ?, mention_everyone = ?`,
+		m.Content, editedAt, m.Mentions, m.MentionRoles, m.MentionEveryone,
+	); err != nil {
+		return err
+	}
+	m.EditedAt = &editedAt
+	return nil
+}
+
+// UpdateMessageAttachments перезаписывает метаданные вложений сообщения
+func UpdateMessageAttachments(m *models.Message) error {
+	if err := updateMessage(m, `attachments = ?`, m.Attachments); err != nil {
+		return err
+	}
+	signAttachments(m)
+	return nil
+}
+
+// DeleteMessage оставляет в таблице tombstone: строка остаётся, текст стирается
+func DeleteMessage(m *models.Message) error {
+	if err := updateMessage(m, `content = '', deleted = true, attachments = null`); err != nil {
+		return err
+	}
+	if err := deleteReactions(m.ChannelID, m.MessageID); err != nil {
+		return err
+	}
+	m.Content = ""
+	m.Deleted = true
+	m.Reactions = nil
+	m.Attachments = nil
+	return nil
 }
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+)
+
+// Направление выборки истории от курсора
+type Direction int
+
+const (
+	Older Direction = iota
+	Newer
+)
+
+// Cursor — позиция в истории канала. Строки упорядочены по (created_at, message_id).
+type Cursor struct {
+	CreatedAt time.Time
+	MessageID gocql.UUID
+	Inclusive bool // включать ли в выборку само сообщение
+}
+
+// CursorFor находит позицию сообщения канала. Если сообщения нет в индексе
+// (или оно из другого канала), позиция берётся из времени его timeuuid.
+func CursorFor(channelID, messageID gocql.UUID) (Cursor, error) {
+	var (
+		idxChannel gocql.UUID
+		createdAt  time.Time
+	)
+	err := Session.Query(fmt.Sprintf(`SELECT channel_id, created_at
+        FROM %s.messages_by_id WHERE message_id = ?`, config.CassandraKeyspace),
+		messageID,
+	).Scan(&idxChannel, &createdAt)
+	switch {
+	case err == nil && idxChannel == channelID:
+		return Cursor{CreatedAt: createdAt, MessageID: messageID}, nil
+	case err == nil, err == gocql.ErrNotFound:
+		return Cursor{CreatedAt: messageID.Time().Truncate(time.Millisecond), MessageID: messageID}, nil
+	default:
+		return Cursor{}, err
+	}
+}
+
+// GetMessages возвращает до limit неудалённых сообщений канала старше (Older)
+// или новее (Newer) from; from == nil — от самого нового сообщения.
+// Результат всегда упорядочен от новых к старым; viewerID нужен, чтобы отметить свои реакции.
+// В новой схеме бакеты обходятся по очереди, пока не наберётся limit.
+func GetMessages(channelID gocql.UUID, from *Cursor, dir Direction, limit int, viewerID string) ([]models.Message, error) {
+	if limit <= 0 || (from == nil && dir == Newer) {
+		return nil, nil
+	}
+
+	var (
+		msgs []models.Message
+		err  error
+	)
+	t := readTable()
+	if !t.bucketed {
+		if msgs, err = scanHistory(t, channelID, nil, from, dir, limit, msgs); err != nil {
+			return nil, err
+		}
+	} else {
+		var start *int64
+		if from != nil {
+			b := Bucket(from.CreatedAt)
+			start = &b
+		}
+		buckets, err := channelBuckets(channelID, start, dir == Older)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range buckets {
+			if len(msgs) >= limit {
+				break
+			}
+			if msgs, err = scanHistory(t, channelID, &b, from, dir, limit, msgs); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if dir == Newer {
+		for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+			msgs[i], msgs[j] = msgs[j], msgs[i]
+		}
+	}
+	if err := attachReactions(channelID, msgs, viewerID); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// scanHistory дочитывает в msgs неудалённые сообщения одной партиции в сторону dir от from,
+// пока их не станет limit
+func scanHistory(t msgTable, channelID gocql.UUID, bucket *int64, from *Cursor, dir Direction, limit int, msgs []models.Message) ([]models.Message, error) {
+	cql := fmt.Sprintf(`SELECT %s FROM %s.%s WHERE channel_id = ?`, messageColumns, config.CassandraKeyspace, t.name)
+	args := []interface{}{channelID}
+	if bucket != nil {
+		cql += " AND bucket = ?"
+		args = append(args, *bucket)
+	}
+	if from != nil {
+		op := map[Direction]string{Older: "<", Newer: ">"}[dir]
+		if from.Inclusive {
+			op += "="
+		}
+		cql += fmt.Sprintf(" AND (created_at, message_id) %s (?, ?)", op)
+		args = append(args, from.CreatedAt, from.MessageID)
+	}
+	if dir == Newer {
+		cql += " ORDER BY " + t.ascOrder
+	}
+
+	iter := Session.Query(cql, args...).PageSize(limit).Iter()
+	var m models.Message
+	for len(msgs) < limit && iter.Scan(messageDest(&m)...) {
+		if !m.Deleted {
+			signAttachments(&m)
+			msgs = append(msgs, m)
+		}
+		m = models.Message{}
+	}
+	return msgs, iter.Close()
+}
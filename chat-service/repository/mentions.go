@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gocql/gocql"
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+)
+
+// AddUserMentions кладёт сообщение во входящие упоминания пользователей.
+// Повторная запись того же сообщения ничего не меняет.
+func AddUserMentions(userIDs []string, guildID string, m *models.Message) error {
+	cql := fmt.Sprintf(`INSERT INTO %s.user_mentions
+        (user_id, message_id, channel_id, guild_id) VALUES (?, ?, ?, ?)`, config.CassandraKeyspace)
+	for _, uid := range userIDs {
+		if err := Session.Query(cql, uid, m.MessageID, m.ChannelID, guildID).Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUserMentions возвращает упоминания пользователя, новые первыми.
+// before — курсор: ID сообщения, после которого продолжать (nil — с начала).
+// Упоминания из каналов, для которых visible вернул false, пропускаются.
+func GetUserMentions(userID string, limit int, before *gocql.UUID, visible func(channelID gocql.UUID) (bool, error)) ([]models.Mention, error) {
+	cql := fmt.Sprintf(`SELECT message_id, channel_id, guild_id FROM %s.user_mentions
+        WHERE user_id = ?`, config.CassandraKeyspace)
+	args := []interface{}{userID}
+	if before != nil {
+		cql += " AND message_id < ?"
+		args = append(args, *before)
+	}
+
+	iter := Session.Query(cql, args...).PageSize(limit).Iter()
+	var (
+		list           []models.Mention
+		mid, channelID gocql.UUID
+		guildID        string
+	)
+	for len(list) < limit && iter.Scan(&mid, &channelID, &guildID) {
+		ok, err := visible(channelID)
+		if err != nil {
+			iter.Close()
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		m, err := GetMessage(channelID, mid)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			iter.Close()
+			return nil, err
+		}
+		if m.Deleted {
+			continue
+		}
+		list = append(list, models.Mention{GuildID: guildID, Message: *m})
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/gocql/gocql"
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+)
+
+// CopyLegacyMessages переносит строки старой messages в messages_by_bucket
+// постранично, начиная с state (nil — с начала). После каждой страницы
+// вызывается progress с числом скопированных строк и состоянием для продолжения.
+//
+// Копия пишется с writetime исходной строки: если сервис в режиме dual
+// успел изменить сообщение после чтения страницы, его запись новее и не затирается.
+func CopyLegacyMessages(state []byte, pageSize int, progress func(copied int, next []byte)) error {
+	for {
+		iter := Session.Query(fmt.Sprintf(`SELECT %s, writetime(content), writetime(thread_id)
+            FROM %s.messages`, messageColumns, config.CassandraKeyspace),
+		).PageSize(pageSize).PageState(state).Iter()
+		next := iter.PageState()
+
+		var (
+			n          int
+			m          models.Message
+			wtContent  int64
+			wtThreadID *int64
+		)
+		for iter.Scan(append(messageDest(&m), &wtContent, &wtThreadID)...) {
+			ts := wtContent
+			if wtThreadID != nil && *wtThreadID > ts {
+				ts = *wtThreadID
+			}
+			if err := copyMessage(&m, ts); err != nil {
+				iter.Close()
+				return err
+			}
+			n++
+			m, wtThreadID = models.Message{}, nil
+		}
+		if err := iter.Close(); err != nil {
+			return err
+		}
+
+		progress(n, next)
+		if len(next) == 0 {
+			return nil
+		}
+		state = next
+	}
+}
+
+func copyMessage(m *models.Message, ts int64) error {
+	b := Session.NewBatch(gocql.LoggedBatch)
+	b.Query(fmt.Sprintf(`INSERT INTO %s.messages_by_bucket
+        (channel_id, bucket, created_at, message_id, sender_id, content, edited_at, deleted,
+         reply_to, thread_id, mentions, mention_roles, mention_everyone, attachments, kind)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) USING TIMESTAMP ?`, config.CassandraKeyspace),
+		m.ChannelID, Bucket(m.CreatedAt), m.CreatedAt, m.MessageID, m.SenderID, m.Content, m.EditedAt, m.Deleted,
+		m.ReplyTo, m.ThreadID, m.Mentions, m.MentionRoles, m.MentionEveryone, m.Attachments, m.Kind, ts,
+	)
+	b.Query(fmt.Sprintf(`INSERT INTO %s.channel_buckets (channel_id, bucket) VALUES (?, ?)`, config.CassandraKeyspace),
+		m.ChannelID, Bucket(m.CreatedAt),
+	)
+	b.Query(fmt.Sprintf(`INSERT INTO %s.messages_by_id (message_id, channel_id, created_at)
+        VALUES (?, ?, ?) USING TIMESTAMP ?`, config.CassandraKeyspace),
+		m.MessageID, m.ChannelID, m.CreatedAt, ts,
+	)
+	return Session.ExecuteBatch(b)
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/yourorg/chat-service/config"
+)
+
+// ClaimNonce закрепляет nonce пользователя за новым сообщением на ttl.
+// Если nonce уже занят, claimed=false, а channelID и messageID — сообщение, за которым он закреплён.
+func ClaimNonce(userID, nonce string, channelID, messageID gocql.UUID, ttl time.Duration) (claimed bool, prevChannel, prevMessage gocql.UUID, err error) {
+	cql := fmt.Sprintf(`INSERT INTO %s.message_nonces (user_id, nonce, channel_id, message_id)
+        VALUES (?, ?, ?, ?) IF NOT EXISTS USING TTL ?`, config.CassandraKeyspace)
+	prev := map[string]interface{}{}
+	claimed, err = Session.Query(cql, userID, nonce, channelID, messageID, int(ttl.Seconds())).MapScanCAS(prev)
+	if err != nil || claimed {
+		return claimed, gocql.UUID{}, gocql.UUID{}, err
+	}
+	prevChannel, _ = prev["channel_id"].(gocql.UUID)
+	prevMessage, _ = prev["message_id"].(gocql.UUID)
+	return false, prevChannel, prevMessage, nil
+}
+
+// ReleaseNonce освобождает nonce, если сообщение так и не сохранилось
+func ReleaseNonce(userID, nonce string) error {
+	return Session.Query(fmt.Sprintf(`DELETE FROM %s.message_nonces WHERE user_id = ? AND nonce = ?`, config.CassandraKeyspace),
+		userID, nonce,
+	).Exec()
+}
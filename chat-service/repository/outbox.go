@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/gocql/gocql"
+	"github.com/yourorg/chat-service/config"
+)
+
+// outboxShard — пока вся очередь в одной партиции, порядок событий сохраняется
+const outboxShard = 0
+
+// OutboxEntry — событие, ждущее отправки в Kafka
+type OutboxEntry struct {
+	ID      gocql.UUID
+	Key     string
+	Payload []byte
+}
+
+// SaveOutbox ставит событие в очередь на отправку
+func SaveOutbox(e OutboxEntry) error {
+	return Session.Query(fmt.Sprintf(`INSERT INTO %s.outbox (shard, event_id, event_key, payload)
+        VALUES (?, ?, ?, ?)`, config.CassandraKeyspace),
+		outboxShard, e.ID, e.Key, e.Payload,
+	).Exec()
+}
+
+// PendingOutbox возвращает до limit неотправленных событий, старые первыми
+func PendingOutbox(limit int) ([]OutboxEntry, error) {
+	iter := Session.Query(fmt.Sprintf(`SELECT event_id, event_key, payload FROM %s.outbox
+        WHERE shard = ? LIMIT ?`, config.CassandraKeyspace),
+		outboxShard, limit,
+	).Iter()
+
+	var (
+		list []OutboxEntry
+		e    OutboxEntry
+	)
+	for iter.Scan(&e.ID, &e.Key, &e.Payload) {
+		list = append(list, e)
+		e = OutboxEntry{}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// DeleteOutbox убирает отправленное событие из очереди
+func DeleteOutbox(id gocql.UUID) error {
+	return Session.Query(fmt.Sprintf(`DELETE FROM %s.outbox WHERE shard = ? AND event_id = ?`, config.CassandraKeyspace),
+		outboxShard, id,
+	).Exec()
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+)
+
+// CountPins возвращает число закреплённых сообщений в канале
+func CountPins(channelID gocql.UUID) (int, error) {
+	var n int
+	err := Session.Query(fmt.Sprintf(`SELECT COUNT(*) FROM %s.pins WHERE channel_id = ?`, config.CassandraKeyspace),
+		channelID,
+	).Scan(&n)
+	return n, err
+}
+
+// PinMessage закрепляет сообщение; applied=false, если оно уже закреплено
+func PinMessage(channelID, messageID gocql.UUID, userID string, at time.Time) (bool, error) {
+	cql := fmt.Sprintf(`INSERT INTO %s.pins (channel_id, message_id, pinned_by, pinned_at)
+        VALUES (?, ?, ?, ?) IF NOT EXISTS`, config.CassandraKeyspace)
+	return Session.Query(cql, channelID, messageID, userID, at).MapScanCAS(map[string]interface{}{})
+}
+
+// UnpinMessage открепляет сообщение; applied=false, если оно не было закреплено
+func UnpinMessage(channelID, messageID gocql.UUID) (bool, error) {
+	cql := fmt.Sprintf(`DELETE FROM %s.pins WHERE channel_id = ? AND message_id = ? IF EXISTS`, config.CassandraKeyspace)
+	return Session.Query(cql, channelID, messageID).MapScanCAS(map[string]interface{}{})
+}
+
+// GetPins возвращает закреплённые сообщения канала, последние закреплённые первыми
+func GetPins(channelID gocql.UUID, viewerID string) ([]models.Pin, error) {
+	iter := Session.Query(fmt.Sprintf(`SELECT message_id, pinned_by, pinned_at FROM %s.pins WHERE channel_id = ?`, config.CassandraKeyspace),
+		channelID,
+	).Iter()
+
+	var (
+		list     []models.Pin
+		mid      gocql.UUID
+		pinnedBy string
+		pinnedAt time.Time
+	)
+	for iter.Scan(&mid, &pinnedBy, &pinnedAt) {
+		m, err := GetMessage(channelID, mid)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			iter.Close()
+			return nil, err
+		}
+		if m.Deleted {
+			continue
+		}
+		list = append(list, models.Pin{PinnedBy: pinnedBy, PinnedAt: pinnedAt, Message: *m})
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].PinnedAt.After(list[j].PinnedAt) })
+
+	msgs := make([]models.Message, len(list))
+	for i := range list {
+		msgs[i] = list[i].Message
+	}
+	if err := attachReactions(channelID, msgs, viewerID); err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Message = msgs[i]
+	}
+	return list, nil
+}
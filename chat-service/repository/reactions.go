@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+)
+
+// AddReaction ставит реакцию; applied=false, если она уже была
+func AddReaction(channelID, messageID gocql.UUID, emoji, userID string) (bool, error) {
+	cql := fmt.Sprintf(`INSERT INTO %s.reactions
+        (channel_id, message_id, emoji, user_id, created_at)
+        VALUES (?, ?, ?, ?, ?) IF NOT EXISTS`, config.CassandraKeyspace)
+	return Session.Query(cql, channelID, messageID, emoji, userID, time.Now()).
+		MapScanCAS(map[string]interface{}{})
+}
+
+// RemoveReaction снимает реакцию; applied=false, если её не было
+func RemoveReaction(channelID, messageID gocql.UUID, emoji, userID string) (bool, error) {
+	cql := fmt.Sprintf(`DELETE FROM %s.reactions
+        WHERE channel_id = ? AND message_id = ? AND emoji = ? AND user_id = ? IF EXISTS`, config.CassandraKeyspace)
+	return Session.Query(cql, channelID, messageID, emoji, userID).
+		MapScanCAS(map[string]interface{}{})
+}
+
+func deleteReactions(channelID, messageID gocql.UUID) error {
+	cql := fmt.Sprintf(`DELETE FROM %s.reactions
+        WHERE channel_id = ? AND message_id = ?`, config.CassandraKeyspace)
+	return Session.Query(cql, channelID, messageID).Exec()
+}
+
+// reactionsBatch ограничивает размер IN-списка в одном запросе
+const reactionsBatch = 100
+
+// attachReactions подтягивает агрегированные реакции для страницы сообщений
+func attachReactions(channelID gocql.UUID, msgs []models.Message, viewerID string) error {
+	pos := make(map[gocql.UUID]int, len(msgs))
+	for i, m := range msgs {
+		pos[m.MessageID] = i
+	}
+
+	cql := fmt.Sprintf(`SELECT message_id, emoji, user_id FROM %s.reactions
+        WHERE channel_id = ? AND message_id IN ?`, config.CassandraKeyspace)
+	for start := 0; start < len(msgs); start += reactionsBatch {
+		end := start + reactionsBatch
+		if end > len(msgs) {
+			end = len(msgs)
+		}
+		ids := make([]gocql.UUID, 0, end-start)
+		for _, m := range msgs[start:end] {
+			ids = append(ids, m.MessageID)
+		}
+
+		iter := Session.Query(cql, channelID, ids).Iter()
+		var (
+			mid           gocql.UUID
+			emoji, userID string
+		)
+		for iter.Scan(&mid, &emoji, &userID) {
+			i, ok := pos[mid]
+			if !ok {
+				continue
+			}
+			// строки отсортированы по emoji, поэтому одинаковые идут подряд
+			rs := msgs[i].Reactions
+			if n := len(rs); n == 0 || rs[n-1].Emoji != emoji {
+				rs = append(rs, models.Reaction{Emoji: emoji})
+			}
+			r := &rs[len(rs)-1]
+			r.Count++
+			if userID == viewerID {
+				r.Me = true
+			}
+			msgs[i].Reactions = rs
+		}
+		if err := iter.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+)
+
+// SaveReadState запоминает последнее прочитанное сообщение канала.
+// Назад тоже можно: так работает «отметить непрочитанным».
+func SaveReadState(userID string, m *models.Message) error {
+	return Session.Query(fmt.Sprintf(`INSERT INTO %s.read_states
+        (user_id, channel_id, last_read_id, last_read_at) VALUES (?, ?, ?, ?)`, config.CassandraKeyspace),
+		userID, m.ChannelID, m.MessageID, m.CreatedAt,
+	).Exec()
+}
+
+// GetReadStates возвращает все отметки прочтения пользователя по ID канала
+func GetReadStates(userID string) (map[gocql.UUID]models.ReadState, error) {
+	iter := Session.Query(fmt.Sprintf(`SELECT channel_id, last_read_id, last_read_at
+        FROM %s.read_states WHERE user_id = ?`, config.CassandraKeyspace),
+		userID,
+	).Iter()
+
+	states := make(map[gocql.UUID]models.ReadState)
+	var rs models.ReadState
+	for iter.Scan(&rs.ChannelID, &rs.LastReadID, &rs.LastReadAt) {
+		states[rs.ChannelID] = rs
+		rs = models.ReadState{}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// CountUnread считает чужие неудалённые сообщения канала новее after, но не больше limit
+func CountUnread(channelID gocql.UUID, after time.Time, userID string, limit int) (int, error) {
+	t := readTable()
+	if !t.bucketed {
+		return countUnread(t, channelID, nil, after, userID, limit)
+	}
+
+	var from *int64
+	if !after.IsZero() {
+		b := Bucket(after)
+		from = &b
+	}
+	buckets, err := channelBuckets(channelID, from, false)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, b := range buckets {
+		if n >= limit {
+			break
+		}
+		c, err := countUnread(t, channelID, &b, after, userID, limit-n)
+		if err != nil {
+			return 0, err
+		}
+		n += c
+	}
+	return n, nil
+}
+
+func countUnread(t msgTable, channelID gocql.UUID, bucket *int64, after time.Time, userID string, limit int) (int, error) {
+	cql := fmt.Sprintf(`SELECT sender_id, deleted FROM %s.%s WHERE channel_id = ?`, config.CassandraKeyspace, t.name)
+	args := []interface{}{channelID}
+	if bucket != nil {
+		cql += " AND bucket = ?"
+		args = append(args, *bucket)
+	}
+	if !after.IsZero() {
+		cql += " AND created_at > ?"
+		args = append(args, after)
+	}
+
+	iter := Session.Query(cql, args...).PageSize(limit).Iter()
+	var (
+		n        int
+		senderID string
+		deleted  bool
+	)
+	for n < limit && iter.Scan(&senderID, &deleted) {
+		if deleted || senderID == userID {
+			continue
+		}
+		n++
+	}
+	return n, iter.Close()
+}
+
+// CountMentions считает входящие упоминания пользователя в гильдии,
+// пришедшие после отметки прочтения своего канала. Каналы вне readAt не считаются;
+// нулевое время — канал ни разу не читали.
+func CountMentions(userID, guildID string, readAt map[gocql.UUID]time.Time) (map[gocql.UUID]int, error) {
+	var oldest time.Time
+	first := true
+	for _, t := range readAt {
+		if first || t.Before(oldest) {
+			oldest, first = t, false
+		}
+	}
+
+	cql := fmt.Sprintf(`SELECT message_id, channel_id, guild_id FROM %s.user_mentions
+        WHERE user_id = ?`, config.CassandraKeyspace)
+	args := []interface{}{userID}
+	if !oldest.IsZero() {
+		cql += " AND message_id > maxTimeuuid(?)"
+		args = append(args, oldest)
+	}
+
+	iter := Session.Query(cql, args...).Iter()
+	counts := make(map[gocql.UUID]int)
+	var (
+		mid, channelID gocql.UUID
+		gid            string
+	)
+	for iter.Scan(&mid, &channelID, &gid) {
+		if gid != guildID {
+			continue
+		}
+		t, ok := readAt[channelID]
+		// в Cassandra время хранится с точностью до миллисекунд
+		if !ok || !mid.Time().Truncate(time.Millisecond).After(t) {
+			continue
+		}
+		counts[channelID]++
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/yourorg/chat-service/config"
+)
+
+// ClaimSlowmode отмечает, что пользователь пишет в канал в медленном режиме.
+// Если interval с прошлого сообщения ещё не прошёл, claimed=false, а prev — время прошлого сообщения.
+func ClaimSlowmode(channelID gocql.UUID, userID string, at time.Time, interval time.Duration) (claimed bool, prev time.Time, err error) {
+	cql := fmt.Sprintf(`INSERT INTO %s.slowmode (channel_id, user_id, sent_at)
+        VALUES (?, ?, ?) IF NOT EXISTS USING TTL ?`, config.CassandraKeyspace)
+	ttl := int((interval + time.Second - 1) / time.Second)
+	row := map[string]interface{}{}
+	claimed, err = Session.Query(cql, channelID, userID, at, ttl).MapScanCAS(row)
+	if err != nil || claimed {
+		return claimed, time.Time{}, err
+	}
+	prev, _ = row["sent_at"].(time.Time)
+	return false, prev, nil
+}
+
+// ReleaseSlowmode снимает отметку ClaimSlowmode, сделанную в момент at, если сообщение так и не сохранилось
+func ReleaseSlowmode(channelID gocql.UUID, userID string, at time.Time) error {
+	cql := fmt.Sprintf(`DELETE FROM %s.slowmode WHERE channel_id = ? AND user_id = ? IF sent_at = ?`, config.CassandraKeyspace)
+	_, err := Session.Query(cql, channelID, userID, at).MapScanCAS(map[string]interface{}{})
+	return err
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+)
+
+// ErrThreadExists — у сообщения уже есть ветка
+var ErrThreadExists = errors.New("thread already exists")
+
+const threadColumns = `channel_id, thread_id, parent_message_id, name, creator_id, created_at,
+        last_message_at, auto_archive_minutes, archived, archived_at`
+
+func threadDest(t *models.Thread) []interface{} {
+	return []interface{}{
+		&t.ChannelID, &t.ThreadID, &t.ParentMessageID, &t.Name, &t.CreatorID, &t.CreatedAt,
+		&t.LastMessageAt, &t.AutoArchiveMinutes, &t.Archived, &t.ArchivedAt,
+	}
+}
+
+// CreateThread привязывает ветку к родительскому сообщению и сохраняет её.
+// Привязка идёт через LWT, поэтому у сообщения может быть только одна ветка.
+func CreateThread(parent *models.Message, t *models.Thread) error {
+	applied, err := casMessage(parent, "thread_id = ?", "thread_id = null", t.ThreadID)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return ErrThreadExists
+	}
+
+	b := Session.NewBatch(gocql.LoggedBatch)
+	b.Query(fmt.Sprintf(`INSERT INTO %s.threads (%s)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, config.CassandraKeyspace, threadColumns),
+		t.ChannelID, t.ThreadID, t.ParentMessageID, t.Name, t.CreatorID, t.CreatedAt,
+		t.LastMessageAt, t.AutoArchiveMinutes, t.Archived, t.ArchivedAt,
+	)
+	b.Query(fmt.Sprintf(`INSERT INTO %s.threads_by_id (thread_id, channel_id) VALUES (?, ?)`, config.CassandraKeyspace),
+		t.ThreadID, t.ChannelID,
+	)
+	if err := Session.ExecuteBatch(b); err != nil {
+		return err
+	}
+	parent.ThreadID = &t.ThreadID
+	return nil
+}
+
+// GetThread ищет ветку по её ID; ErrNotFound, если это не ветка
+func GetThread(threadID gocql.UUID) (*models.Thread, error) {
+	var channelID gocql.UUID
+	err := Session.Query(fmt.Sprintf(`SELECT channel_id FROM %s.threads_by_id WHERE thread_id = ?`, config.CassandraKeyspace),
+		threadID,
+	).Scan(&channelID)
+	if err == gocql.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var t models.Thread
+	err = Session.Query(fmt.Sprintf(`SELECT %s FROM %s.threads WHERE channel_id = ? AND thread_id = ?`, threadColumns, config.CassandraKeyspace),
+		channelID, threadID,
+	).Scan(threadDest(&t)...)
+	if err == gocql.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetThreads возвращает ветки канала, новые первыми
+func GetThreads(channelID gocql.UUID) ([]models.Thread, error) {
+	iter := Session.Query(fmt.Sprintf(`SELECT %s FROM %s.threads WHERE channel_id = ?`, threadColumns, config.CassandraKeyspace),
+		channelID,
+	).Iter()
+
+	var list []models.Thread
+	var t models.Thread
+	for iter.Scan(threadDest(&t)...) {
+		list = append(list, t)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// TouchThread отмечает активность в ветке
+func TouchThread(t *models.Thread, at time.Time) error {
+	cql := fmt.Sprintf(`UPDATE %s.threads SET last_message_at = ?
+        WHERE channel_id = ? AND thread_id = ?`, config.CassandraKeyspace)
+	if err := Session.Query(cql, at, t.ChannelID, t.ThreadID).Exec(); err != nil {
+		return err
+	}
+	t.LastMessageAt = at
+	return nil
+}
+
+// UpdateThread сохраняет имя и состояние архивации ветки
+func UpdateThread(t *models.Thread) error {
+	cql := fmt.Sprintf(`UPDATE %s.threads SET name = ?, archived = ?, archived_at = ?, last_message_at = ?
+        WHERE channel_id = ? AND thread_id = ?`, config.CassandraKeyspace)
+	return Session.Query(cql,
+		t.Name, t.Archived, t.ArchivedAt, t.LastMessageAt, t.ChannelID, t.ThreadID,
+	).Exec()
+}
+
+// ForEachActiveThread обходит все неархивные ветки; используется автоархивацией
+func ForEachActiveThread(fn func(t *models.Thread)) error {
+	iter := Session.Query(fmt.Sprintf(`SELECT %s FROM %s.threads WHERE archived = false ALLOW FILTERING`, threadColumns, config.CassandraKeyspace)).
+		PageSize(500).Iter()
+
+	var t models.Thread
+	for iter.Scan(threadDest(&t)...) {
+		cur := t
+		fn(&cur)
+	}
+	return iter.Close()
+}
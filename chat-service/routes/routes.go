@@ -1,13 +1,12 @@
 package routes
 
 import (
+    "expvar"
     "net/http"
-    "strconv"
-    "time"
 
     "github.com/gin-gonic/gin"
+    "github.com/yourorg/chat-service/handlers"
     "github.com/yourorg/chat-service/middleware"
-    "github.com/yourorg/chat-service/repository"
     "github.com/yourorg/chat-service/ws"
 )
 
@@ -15,35 +14,58 @@ func Register(r *gin.Engine, hub *ws.Hub) {
     auth := r.Group("/", middleware.JWTAuth())
 
     // HTTP: история сообщений
-    auth.GET("/channels/:channelId/messages", func(c *gin.Context) {
-        channelID := c.Param("channelId")
-        limitStr := c.Query("limit")
-        afterStr := c.Query("after")
-
-        limit := 50
-        if v, err := strconv.Atoi(limitStr); err == nil && v > 0 {
-            limit = v
-        }
-        var after time.Time
-        if afterStr != "" {
-            if t, err := time.Parse(time.RFC3339, afterStr); err == nil {
-                after = t
-            }
-        }
-
-        msgs, err := repository.GetMessages(channelID, limit, after)
-        if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-            return
-        }
-        c.JSON(http.StatusOK, msgs)
-    })
+    auth.GET("/channels/:channelId/messages", handlers.GetMessages())
+
+    // HTTP: отправка, редактирование и удаление сообщений
+    auth.POST("/channels/:channelId/messages", handlers.CreateMessage(hub))
+    auth.PATCH("/channels/:channelId/messages/:messageId", handlers.EditMessage(hub))
+    auth.DELETE("/channels/:channelId/messages/:messageId", handlers.DeleteMessage(hub))
+
+    // HTTP: реакции
+    auth.PUT("/channels/:channelId/messages/:messageId/reactions/:emoji/@me", handlers.AddReaction(hub))
+    auth.DELETE("/channels/:channelId/messages/:messageId/reactions/:emoji/@me", handlers.RemoveReaction(hub))
+
+    // HTTP: ветки. Сообщения ветки читаются через /channels/:threadId/messages,
+    // а в WebSocket ветка подписывается как обычный канал (channelId=threadId)
+    auth.POST("/channels/:channelId/messages/:messageId/threads", handlers.CreateThread(hub))
+    auth.GET("/channels/:channelId/threads", handlers.GetThreads())
+    auth.PATCH("/channels/:channelId/threads/:threadId", handlers.UpdateThread(hub))
+
+    // HTTP: закреплённые сообщения
+    auth.GET("/channels/:channelId/pins", handlers.GetPins())
+    auth.PUT("/channels/:channelId/pins/:messageId", handlers.PinMessage(hub))
+    auth.DELETE("/channels/:channelId/pins/:messageId", handlers.UnpinMessage(hub))
+
+    // HTTP: вложения. Скачивание — по подписанной ссылке, без JWT
+    auth.POST("/channels/:channelId/attachments", handlers.UploadAttachment())
+    r.GET("/channels/:channelId/attachments/:attachmentId/:filename", handlers.DownloadAttachment())
+
+    // HTTP: отметки прочтения
+    auth.POST("/channels/:channelId/messages/:messageId/ack", handlers.AckMessage(hub))
+    auth.GET("/guilds/:guildId/read-states", handlers.GetGuildReadStates())
+
+    // HTTP: поиск по сообщениям гильдии
+    auth.GET("/guilds/:guildId/messages/search", handlers.SearchMessages())
+
+    // HTTP: входящие упоминания текущего пользователя
+    auth.GET("/users/@me/mentions", handlers.GetMyMentions())
 
     // WebSocket: real-time чат
     auth.GET("/ws/chat", ws.ServeWS(hub))
 
+    // Внутренние вызовы guild-service: только с SERVICE_TOKEN, через Kong не публикуются
+    internal := r.Group("/internal", middleware.ServiceAuth())
+    // чистка сообщений забаненного
+    internal.POST("/guilds/:guildId/members/:userId/messages/purge", handlers.PurgeMessages(hub))
+    // права пользователей изменились (кик, бан, тайм-аут, роли, переопределения) — забыть закэшированные
+    internal.POST("/permissions/forget", handlers.ForgetPermissions(hub))
+    // кто подключён к чату — для уборки временных участников гильдий
+    internal.POST("/presence", handlers.GetPresence(hub))
+
     // health
     r.GET("/health", func(c *gin.Context) {
         c.String(http.StatusOK, "ok")
     })
+    // метрики (expvar): соединения и потерянные кадры WS. Наружу через Kong не публикуется
+    r.GET("/debug/vars", gin.WrapH(expvar.Handler()))
 }
@@ -0,0 +1,147 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/outbox"
+	"github.com/yourorg/chat-service/repository"
+)
+
+// Индекс у каждой реплики свой. Чтобы в нём были сообщения со всех реплик,
+// при включённых событиях чата (CHAT_EVENTS) он пополняется не из обработчиков
+// запросов, а из топика событий: каждая реплика читает все партиции без consumer group.
+// Прочитанные смещения хранятся в самом индексе, после рестарта чтение продолжается с них.
+
+// События, которые меняют индекс
+const (
+	eventMessageCreate = "MESSAGE_CREATE"
+	eventMessageUpdate = "MESSAGE_UPDATE"
+	eventMessageDelete = "MESSAGE_DELETE"
+)
+
+// FromEvents сообщает, что индекс пополняется из топика событий
+func FromEvents() bool {
+	return config.ChatEventsEnabled
+}
+
+// Follow запускает чтение топика событий в индекс
+func Follow() {
+	if !FromEvents() {
+		return
+	}
+	go func() {
+		// Kafka в compose поднимается дольше сервиса — пробуем, пока не выйдет
+		for {
+			err := follow()
+			if err == nil {
+				return
+			}
+			log.Println("search: follow events:", err)
+			time.Sleep(5 * time.Second)
+		}
+	}()
+}
+
+func follow() error {
+	consumer, err := sarama.NewConsumer(config.KafkaBrokers, sarama.NewConfig())
+	if err != nil {
+		return err
+	}
+	partitions, err := consumer.Partitions(config.ChatEventsTopic)
+	if err != nil {
+		consumer.Close()
+		return err
+	}
+	for _, p := range partitions {
+		pc, err := consumePartition(consumer, p)
+		if err != nil {
+			consumer.Close()
+			return err
+		}
+		go func(p int32, pc sarama.PartitionConsumer) {
+			for msg := range pc.Messages() {
+				if err := apply(msg.Value); err != nil {
+					log.Println("search: apply event:", err)
+				}
+				saveOffset(p, msg.Offset)
+			}
+		}(p, pc)
+	}
+	return nil
+}
+
+// consumePartition продолжает чтение партиции с сохранённого смещения;
+// если его нет или оно уже вытеснено из топика — с самого начала
+func consumePartition(consumer sarama.Consumer, p int32) (sarama.PartitionConsumer, error) {
+	if next, ok := loadOffset(p); ok {
+		pc, err := consumer.ConsumePartition(config.ChatEventsTopic, p, next)
+		if !errors.Is(err, sarama.ErrOffsetOutOfRange) {
+			return pc, err
+		}
+	}
+	return consumer.ConsumePartition(config.ChatEventsTopic, p, sarama.OffsetOldest)
+}
+
+func offsetKey(p int32) []byte {
+	return []byte("events-offset/" + strconv.Itoa(int(p)))
+}
+
+// loadOffset возвращает смещение, с которого продолжать партицию
+func loadOffset(p int32) (int64, bool) {
+	v, err := Index.GetInternal(offsetKey(p))
+	if err != nil || v == nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(v), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n + 1, true
+}
+
+func saveOffset(p int32, offset int64) {
+	if err := Index.SetInternal(offsetKey(p), []byte(strconv.FormatInt(offset, 10))); err != nil {
+		log.Println("search: save offset:", err)
+	}
+}
+
+// apply переносит событие чата в индекс
+func apply(value []byte) error {
+	var e outbox.Event
+	if err := json.Unmarshal(value, &e); err != nil {
+		return err
+	}
+	switch e.Type {
+	case eventMessageCreate, eventMessageUpdate:
+		var m models.Message
+		if err := json.Unmarshal(e.Payload, &m); err != nil {
+			return err
+		}
+		parentID := m.ChannelID.String()
+		t, err := repository.GetThread(m.ChannelID)
+		switch {
+		case err == nil:
+			parentID = t.ChannelID.String()
+		case !errors.Is(err, repository.ErrNotFound):
+			return err
+		}
+		return indexMessage(&m, parentID)
+	case eventMessageDelete:
+		var d struct {
+			MessageID string `json:"messageId"`
+		}
+		if err := json.Unmarshal(e.Payload, &d); err != nil {
+			return err
+		}
+		return removeMessage(d.MessageID)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package search
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/mapping"
+
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+)
+
+// Полнотекстовый индекс сообщений. Индекс встроенный (Bleve) и лежит
+// на диске рядом с сервисом; как он получает сообщения всех реплик — см. follow.go.
+
+// Index — открытый индекс, создаётся в Init
+var Index bleve.Index
+
+// document — то, что попадает в индекс для одного сообщения
+type document struct {
+	ChannelID string    `json:"channelId"` // канал или ветка, где лежит сообщение
+	ParentID  string    `json:"parentId"`  // канал гильдии (для веток — родительский)
+	SenderID  string    `json:"senderId"`
+	Content   string    `json:"content"`
+	Mentions  []string  `json:"mentions"`
+	HasLink   bool      `json:"hasLink"`
+	HasFile   bool      `json:"hasFile"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+var linkRe = regexp.MustCompile(`(?i)\bhttps?://\S+`)
+
+// Init открывает индекс, создавая его при первом запуске
+func Init() {
+	idx, err := bleve.Open(config.SearchIndexPath)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		if err := os.MkdirAll(filepath.Dir(config.SearchIndexPath), 0o755); err != nil {
+			log.Fatalf("search index dir: %v", err)
+		}
+		idx, err = bleve.New(config.SearchIndexPath, buildMapping())
+	}
+	if err != nil {
+		log.Fatalf("search index %s: %v", config.SearchIndexPath, err)
+	}
+	Index = idx
+	log.Printf("search index: %s", config.SearchIndexPath)
+}
+
+func buildMapping() mapping.IndexMapping {
+	kw := bleve.NewKeywordFieldMapping()
+	kw.Analyzer = keyword.Name
+
+	text := bleve.NewTextFieldMapping()
+	text.Store = false
+
+	doc := bleve.NewDocumentStaticMapping()
+	doc.AddFieldMappingsAt("channelId", kw)
+	doc.AddFieldMappingsAt("parentId", kw)
+	doc.AddFieldMappingsAt("senderId", kw)
+	doc.AddFieldMappingsAt("mentions", kw)
+	doc.AddFieldMappingsAt("content", text)
+	doc.AddFieldMappingsAt("hasLink", bleve.NewBooleanFieldMapping())
+	doc.AddFieldMappingsAt("hasFile", bleve.NewBooleanFieldMapping())
+	doc.AddFieldMappingsAt("createdAt", bleve.NewDateTimeFieldMapping())
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	return m
+}
+
+// IndexMessage добавляет или переиндексирует сообщение.
+// parentID — канал гильдии; для сообщений в ветке это её родительский канал.
+// Если индекс пополняется из топика событий, ничего не делает: сообщение придёт оттуда.
+func IndexMessage(m *models.Message, parentID string) error {
+	if FromEvents() {
+		return nil
+	}
+	return indexMessage(m, parentID)
+}
+
+func indexMessage(m *models.Message, parentID string) error {
+	if Index == nil || m.Kind != "" {
+		// системные сообщения в поиск не попадают
+		return nil
+	}
+	mentions := m.Mentions
+	if m.MentionEveryone {
+		mentions = append(append([]string(nil), mentions...), "everyone")
+	}
+	return Index.Index(m.MessageID.String(), document{
+		ChannelID: m.ChannelID.String(),
+		ParentID:  parentID,
+		SenderID:  m.SenderID,
+		Content:   m.Content,
+		Mentions:  mentions,
+		HasLink:   linkRe.MatchString(m.Content),
+		HasFile:   len(m.Attachments) > 0,
+		CreatedAt: m.CreatedAt,
+	})
+}
+
+// RemoveMessage убирает сообщение из индекса; при пополнении из топика событий — ничего не делает
+func RemoveMessage(messageID string) error {
+	if FromEvents() {
+		return nil
+	}
+	return removeMessage(messageID)
+}
+
+func removeMessage(messageID string) error {
+	if Index == nil {
+		return nil
+	}
+	return Index.Delete(messageID)
+}
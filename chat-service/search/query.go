@@ -0,0 +1,183 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+var ErrBadQuery = errors.New("bad search query")
+
+// Query — разобранная строка поиска: свободный текст плюс фильтры
+// from:, in:, has:link, has:file, before:, after:, mentions:
+type Query struct {
+	Text     string
+	From     []string // ID авторов
+	In       []string // каналы, как их ввёл пользователь: ID или имя
+	Mentions []string // ID упомянутых пользователей; "everyone" — @everyone/@here
+	HasLink  bool
+	HasFile  bool
+	Before   time.Time
+	After    time.Time
+}
+
+// Parse разбирает строку вида `релиз from:<id> in:general has:link after:2024-05-01`.
+// Неизвестные префиксы остаются частью текста.
+func Parse(s string) (Query, error) {
+	var (
+		q    Query
+		text []string
+	)
+	for _, tok := range strings.Fields(s) {
+		key, val, ok := strings.Cut(tok, ":")
+		if !ok || val == "" {
+			text = append(text, tok)
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "from":
+			q.From = append(q.From, userRef(val))
+		case "mentions":
+			q.Mentions = append(q.Mentions, userRef(val))
+		case "in":
+			q.In = append(q.In, strings.TrimPrefix(val, "#"))
+		case "has":
+			switch strings.ToLower(val) {
+			case "link":
+				q.HasLink = true
+			case "file":
+				q.HasFile = true
+			default:
+				return q, fmt.Errorf("%w: unknown has:%s", ErrBadQuery, val)
+			}
+		case "before":
+			t, err := parseDate(val, false)
+			if err != nil {
+				return q, err
+			}
+			q.Before = t
+		case "after":
+			t, err := parseDate(val, true)
+			if err != nil {
+				return q, err
+			}
+			q.After = t
+		default:
+			text = append(text, tok)
+		}
+	}
+	q.Text = strings.Join(text, " ")
+	return q, nil
+}
+
+// userRef принимает ID как есть, а также формы <@id> и @id
+func userRef(v string) string {
+	v = strings.TrimPrefix(strings.TrimSuffix(v, ">"), "<")
+	v = strings.TrimPrefix(v, "@")
+	if v == "here" {
+		return "everyone"
+	}
+	return v
+}
+
+// parseDate понимает YYYY-MM-DD и RFC3339. Дата без времени
+// исключается целиком: before: — до её начала, after: — после её конца.
+func parseDate(v string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: bad date %q", ErrBadQuery, v)
+	}
+	if endOfDay {
+		t = t.Add(24 * time.Hour)
+	}
+	return t, nil
+}
+
+// Hit — найденное сообщение
+type Hit struct {
+	ChannelID string
+	MessageID string
+}
+
+// Search ищет сообщения, новые первыми. Поиск ограничен каналами гильдии
+// parents (с их ветками) и отдельными ветками threads; пустая область — пустой ответ.
+func Search(q Query, parents, threads []string, limit, offset int) ([]Hit, uint64, error) {
+	if Index == nil || len(parents)+len(threads) == 0 {
+		return nil, 0, nil
+	}
+
+	scope := bleve.NewDisjunctionQuery()
+	for _, id := range parents {
+		scope.AddQuery(term("parentId", id))
+	}
+	for _, id := range threads {
+		scope.AddQuery(term("channelId", id))
+	}
+	must := []query.Query{scope}
+
+	if q.Text != "" {
+		mq := bleve.NewMatchQuery(q.Text)
+		mq.SetField("content")
+		mq.SetOperator(query.MatchQueryOperatorAnd)
+		must = append(must, mq)
+	}
+	if len(q.From) > 0 {
+		must = append(must, anyOf("senderId", q.From))
+	}
+	if len(q.Mentions) > 0 {
+		must = append(must, anyOf("mentions", q.Mentions))
+	}
+	if q.HasLink {
+		must = append(must, flag("hasLink"))
+	}
+	if q.HasFile {
+		must = append(must, flag("hasFile"))
+	}
+	if !q.Before.IsZero() || !q.After.IsZero() {
+		dq := bleve.NewDateRangeQuery(q.After, q.Before)
+		dq.SetField("createdAt")
+		must = append(must, dq)
+	}
+
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(must...), limit, offset, false)
+	req.Fields = []string{"channelId"}
+	req.SortBy([]string{"-createdAt"})
+
+	res, err := Index.Search(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	hits := make([]Hit, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		cid, _ := h.Fields["channelId"].(string)
+		hits = append(hits, Hit{ChannelID: cid, MessageID: h.ID})
+	}
+	return hits, res.Total, nil
+}
+
+func term(field, val string) query.Query {
+	tq := bleve.NewTermQuery(val)
+	tq.SetField(field)
+	return tq
+}
+
+func anyOf(field string, vals []string) query.Query {
+	dq := bleve.NewDisjunctionQuery()
+	for _, v := range vals {
+		dq.AddQuery(term(field, v))
+	}
+	return dq
+}
+
+func flag(field string) query.Query {
+	bq := bleve.NewBoolFieldQuery(true)
+	bq.SetField(field)
+	return bq
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/yourorg/chat-service/config"
+)
+
+// Ссылки на вложения подписываются JWT на том же секрете, что и токены пользователей.
+// В подписи нет sub, поэтому как токен авторизации она не пройдёт — и наоборот.
+
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// VariantThumbnail — значение параметра variant для ссылки на превью
+const VariantThumbnail = "thumbnail"
+
+// SignURL возвращает ссылку на скачивание вложения, живущую ATTACHMENT_URL_TTL.
+// Срок округляется, чтобы в пределах окна ссылка не менялась и кешировалась клиентом.
+func SignURL(channelID, attachmentID, filename string) string {
+	ttl := config.AttachmentURLTTL
+	exp := time.Now().Truncate(ttl / 2).Add(ttl)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"aid": attachmentID,
+		"exp": exp.Unix(),
+	})
+	sig, err := token.SignedString([]byte(config.JWTSecret))
+	if err != nil {
+		log.Println("sign attachment url:", err)
+		return ""
+	}
+	return fmt.Sprintf("%s/channels/%s/attachments/%s/%s?sig=%s",
+		config.PublicURL, channelID, attachmentID, url.PathEscape(filename), sig)
+}
+
+// VerifySignature проверяет подпись ссылки для конкретного вложения
+func VerifySignature(sig, attachmentID string) error {
+	token, err := jwt.Parse(sig, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return []byte(config.JWTSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return ErrInvalidSignature
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ErrInvalidSignature
+	}
+	// exp обязателен: бессрочных ссылок не бывает
+	if _, ok := claims["exp"]; !ok {
+		return ErrInvalidSignature
+	}
+	if aid, _ := claims["aid"].(string); aid != attachmentID {
+		return ErrInvalidSignature
+	}
+	return nil
+}
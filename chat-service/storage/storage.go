@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+
+	"github.com/yourorg/chat-service/config"
+)
+
+// Store — хранилище файлов вложений. Ключ — путь вида "attachments/<channel>/<id>/<file>".
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// ErrNotFound — файла с таким ключом нет
+var ErrNotFound = errors.New("blob not found")
+
+// Default — хранилище, выбранное через STORAGE_BACKEND
+var Default Store
+
+func Init() {
+	switch config.StorageBackend {
+	case "fs":
+		fs, err := NewFS(config.StorageDir)
+		if err != nil {
+			log.Fatalf("storage: %v", err)
+		}
+		Default = fs
+	case "s3":
+		s3, err := NewS3(config.S3Endpoint, config.S3Bucket, config.S3AccessKey, config.S3SecretKey, config.S3Region, config.S3UseSSL)
+		if err != nil {
+			log.Fatalf("storage: %v", err)
+		}
+		Default = s3
+	default:
+		log.Fatalf("storage: unknown STORAGE_BACKEND %q", config.StorageBackend)
+	}
+	log.Printf("storage: backend %s", config.StorageBackend)
+}
@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"github.com/gocql/gocql"
+
+	"github.com/yourorg/chat-service/guilds"
+)
+
+// Доступ к каналу (или ветке) есть у тех, кому guild-service даёт VIEW_CHANNEL в нём
+// (для ветки — в родительском канале). Проверка общая для REST и WebSocket;
+// ответы guild-service кэшируются в guilds.
+
+// authorize пропускает actor, если он видит канал
+func authorize(actor Actor, channelID gocql.UUID) error {
+	return checkAccess(actor, channelID, guilds.PermViewChannel, false)
+}
+
+// authorizeFresh — то же, но мимо кэша: для редких действий вроде подписки,
+// где устаревший отказ мешает только что получившему доступ
+func authorizeFresh(actor Actor, channelID gocql.UUID) error {
+	return checkAccess(actor, channelID, guilds.PermViewChannel, true)
+}
+
+// authorizeWith — то же, что authorize, но кроме VIEW_CHANNEL требует ещё perm
+func authorizeWith(actor Actor, channelID gocql.UUID, perm int64) error {
+	return checkAccess(actor, channelID, guilds.PermViewChannel|perm, false)
+}
+
+func checkAccess(actor Actor, channelID gocql.UUID, perm int64, fresh bool) error {
+	parentID, err := parentChannel(channelID)
+	if err != nil {
+		return err
+	}
+	if fresh {
+		guilds.ForgetChannel(parentID)
+		guilds.ForgetChannelPermissions(parentID, actor.UserID)
+	}
+	perms, err := guilds.ChannelPermissions(actor.Token, parentID, actor.UserID)
+	if err != nil {
+		return err
+	}
+	if perms&perm != perm {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// authorizeID разбирает ID канала и проверяет доступ к нему
+func authorizeID(actor Actor, channelID string) (gocql.UUID, error) {
+	cid, err := gocql.ParseUUID(channelID)
+	if err != nil {
+		return gocql.UUID{}, ErrInvalidID
+	}
+	return cid, authorize(actor, cid)
+}
+
+// authorizeSend — как authorizeID, но ещё требует SEND_MESSAGES
+func authorizeSend(actor Actor, channelID string) (gocql.UUID, error) {
+	cid, err := gocql.ParseUUID(channelID)
+	if err != nil {
+		return gocql.UUID{}, ErrInvalidID
+	}
+	return cid, authorizeWith(actor, cid, guilds.PermSendMessages)
+}
+
+// authorizeRead — как authorizeID, но ещё требует READ_MESSAGE_HISTORY:
+// без него канал виден, а уже отправленные сообщения — нет
+func authorizeRead(actor Actor, channelID string) (gocql.UUID, error) {
+	cid, err := gocql.ParseUUID(channelID)
+	if err != nil {
+		return gocql.UUID{}, ErrInvalidID
+	}
+	return cid, authorizeWith(actor, cid, guilds.PermReadMessageHistory)
+}
@@ -0,0 +1,243 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gocql/gocql"
+	_ "golang.org/x/image/webp"
+
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/guilds"
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/repository"
+	"github.com/yourorg/chat-service/storage"
+)
+
+const (
+	maxAttachments  = 10
+	maxFilenameLen  = 128
+	defaultFilename = "file"
+)
+
+var (
+	ErrTooManyAttachments = errors.New("too many attachments")
+	ErrInvalidAttachment  = errors.New("invalid attachment")
+	ErrFileTooLarge       = errors.New("file too large")
+)
+
+// UploadLimit возвращает максимальный размер файла для канала: лимит гильдии или общий
+func UploadLimit(actor Actor, channelID string) (int64, error) {
+	cid, err := authorizeID(actor, channelID)
+	if err != nil {
+		return 0, err
+	}
+	parentID, err := parentChannel(cid)
+	if err != nil {
+		return 0, err
+	}
+	g, err := guilds.GuildForChannel(actor.Token, parentID)
+	if err != nil {
+		return 0, err
+	}
+	if g.MaxUploadBytes > 0 {
+		return g.MaxUploadBytes, nil
+	}
+	return config.MaxUploadBytes, nil
+}
+
+// Upload сохраняет файл в хранилище и записывает его метаданные.
+// К сообщению файл привязывается позже, через attachments в MESSAGE_CREATE.
+func Upload(ctx context.Context, actor Actor, channelID string, limit int64, file multipart.File, header *multipart.FileHeader) (*models.Upload, error) {
+	cid, err := gocql.ParseUUID(channelID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	if err := authorizeWith(actor, cid, guilds.PermAttachFiles); err != nil {
+		return nil, err
+	}
+	if header.Size > limit {
+		return nil, ErrFileTooLarge
+	}
+
+	// Тип определяем по содержимому, заголовку клиента не доверяем
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+
+	u := &models.Upload{
+		Attachment: models.Attachment{
+			ID:          gocql.TimeUUID(),
+			Filename:    cleanFilename(header.Filename),
+			Size:        header.Size,
+			ContentType: contentType,
+		},
+		ChannelID:  cid,
+		UploaderID: actor.UserID,
+		CreatedAt:  time.Now(),
+	}
+	u.Key = path.Join("attachments", cid.String(), u.ID.String(), u.Filename)
+
+	if strings.HasPrefix(contentType, "image/") {
+		if cfg, _, err := image.DecodeConfig(io.MultiReader(bytes.NewReader(head), file)); err == nil {
+			u.Width, u.Height = cfg.Width, cfg.Height
+		}
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if err := storage.Default.Put(ctx, u.Key, file, u.Size, u.ContentType); err != nil {
+		return nil, err
+	}
+	if err := repository.SaveUpload(u); err != nil {
+		storage.Default.Delete(ctx, u.Key)
+		return nil, err
+	}
+	return u, nil
+}
+
+// cleanFilename оставляет от имени файла безопасную для пути и URL часть
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, r == 0x7f, strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return defaultFilename
+	}
+	for utf8.RuneCountInString(name) > maxFilenameLen {
+		_, size := utf8.DecodeRuneInString(name)
+		name = name[size:]
+	}
+	return name
+}
+
+// attachUploads закрепляет загруженные файлы за новым сообщением.
+// Приложить можно только свой файл, загруженный в этот же канал, и только с ATTACH_FILES.
+func attachUploads(actor Actor, m *models.Message, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := authorizeWith(actor, m.ChannelID, guilds.PermAttachFiles); err != nil {
+		return err
+	}
+	for _, raw := range ids {
+		id, err := gocql.ParseUUID(raw)
+		if err != nil {
+			return ErrInvalidAttachment
+		}
+		u, err := repository.GetUpload(id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidAttachment
+		}
+		if err != nil {
+			return err
+		}
+		if u.ChannelID != m.ChannelID || u.UploaderID != actor.UserID {
+			return ErrInvalidAttachment
+		}
+		if err := repository.LinkUpload(u, m.MessageID); err != nil {
+			// Уже закреплённые за этим сообщением файлы не должны пропасть
+			releaseUploads(m)
+			return err
+		}
+		m.Attachments = append(m.Attachments, u.Attachment)
+	}
+	return nil
+}
+
+// releaseUploads возвращает файлы несохранённого сообщения m, чтобы их можно было приложить снова
+func releaseUploads(m *models.Message) {
+	for _, a := range m.Attachments {
+		if err := repository.UnlinkUpload(a.ID, m.MessageID); err != nil {
+			log.Println("release upload:", err)
+		}
+	}
+	m.Attachments = nil
+}
+
+// removeUploads удаляет файлы удалённого сообщения из хранилища
+func removeUploads(attachments []models.Attachment) {
+	ctx := context.Background()
+	for _, a := range attachments {
+		u, err := repository.GetUpload(a.ID)
+		if err != nil {
+			log.Println("remove upload:", err)
+			continue
+		}
+		if err := storage.Default.Delete(ctx, u.Key); err != nil {
+			log.Println("remove upload blob:", err)
+			continue
+		}
+		if u.ThumbnailKey != "" && u.ThumbnailKey != u.Key {
+			if err := storage.Default.Delete(ctx, u.ThumbnailKey); err != nil {
+				log.Println("remove upload thumbnail:", err)
+			}
+		}
+		if err := repository.DeleteUpload(u.ID); err != nil {
+			log.Println("remove upload row:", err)
+		}
+	}
+}
+
+// OpenAttachment проверяет подпись ссылки и открывает файл (или его превью) на чтение
+func OpenAttachment(ctx context.Context, channelID, attachmentID, variant, sig string) (*models.Upload, io.ReadCloser, error) {
+	if err := storage.VerifySignature(sig, attachmentID); err != nil {
+		return nil, nil, err
+	}
+	id, err := gocql.ParseUUID(attachmentID)
+	if err != nil {
+		return nil, nil, ErrInvalidID
+	}
+	u, err := repository.GetUpload(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.ChannelID.String() != strings.ToLower(channelID) {
+		return nil, nil, repository.ErrNotFound
+	}
+	key := u.Key
+	if variant == storage.VariantThumbnail {
+		if u.ThumbnailKey == "" {
+			return nil, nil, repository.ErrNotFound
+		}
+		if u.ThumbnailKey != u.Key {
+			// Отдельный файл превью: размер заранее неизвестен, тип — по расширению
+			key = u.ThumbnailKey
+			u.Size = -1
+			u.ContentType = mime.TypeByExtension(path.Ext(key))
+		}
+	}
+	r, err := storage.Default.Open(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return u, r, nil
+}
@@ -0,0 +1,105 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/yourorg/chat-service/models"
+)
+
+// Типы кадров чата (входящих и исходящих)
+const (
+	EventMessageCreate  = "MESSAGE_CREATE"
+	EventMessageUpdate  = "MESSAGE_UPDATE"
+	EventMessageDelete  = "MESSAGE_DELETE"
+	EventReactionAdd    = "REACTION_ADD"
+	EventReactionRemove = "REACTION_REMOVE"
+	EventThreadCreate   = "THREAD_CREATE"
+	EventThreadUpdate   = "THREAD_UPDATE"
+	EventPinsUpdate     = "CHANNEL_PINS_UPDATE"
+	EventTypingStart    = "TYPING_START"
+	EventTypingStop     = "TYPING_STOP"
+	EventMessageAck     = "MESSAGE_ACK"
+	EventSubscribe      = "SUBSCRIBE"
+	EventUnsubscribe    = "UNSUBSCRIBE"
+	EventSubscribed     = "SUBSCRIBED"
+	EventUnsubscribed   = "UNSUBSCRIBED"
+	EventError          = "ERROR"
+	EventRateLimited    = "RATE_LIMITED"
+)
+
+// MessageEvent — исходящий кадр с сообщением целиком.
+// Поля сообщения лежат на верхнем уровне рядом с type.
+type MessageEvent struct {
+	Type string `json:"type"`
+	*models.Message
+}
+
+// MessageDeleteEvent — исходящий кадр об удалении сообщения
+type MessageDeleteEvent struct {
+	Type      string `json:"type"`
+	ChannelID string `json:"channelId"`
+	MessageID string `json:"messageId"`
+}
+
+// ReactionEvent — исходящий кадр REACTION_ADD / REACTION_REMOVE
+type ReactionEvent struct {
+	Type      string `json:"type"`
+	ChannelID string `json:"channelId"`
+	MessageID string `json:"messageId"`
+	UserID    string `json:"userId"`
+	Emoji     string `json:"emoji"`
+}
+
+// ThreadEvent — исходящий кадр THREAD_CREATE / THREAD_UPDATE
+type ThreadEvent struct {
+	Type string `json:"type"`
+	*models.Thread
+}
+
+// PinsUpdateEvent — исходящий кадр CHANNEL_PINS_UPDATE
+type PinsUpdateEvent struct {
+	Type      string `json:"type"`
+	ChannelID string `json:"channelId"`
+}
+
+// TypingEvent — исходящий кадр TYPING_START / TYPING_STOP.
+// Отправитель получает и свой кадр — клиент сам отфильтрует по userId.
+type TypingEvent struct {
+	Type      string    `json:"type"`
+	ChannelID string    `json:"channelId"`
+	UserID    string    `json:"userId"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// AckEvent — исходящий кадр MESSAGE_ACK: пользователь дочитал канал до сообщения.
+// Приходит только в сессии этого пользователя.
+type AckEvent struct {
+	Type      string `json:"type"`
+	ChannelID string `json:"channelId"`
+	MessageID string `json:"messageId"`
+}
+
+// SubscriptionEvent — ответ на SUBSCRIBE / UNSUBSCRIBE со списком затронутых каналов
+type SubscriptionEvent struct {
+	Type       string   `json:"type"`
+	ChannelIDs []string `json:"channelIds"`
+}
+
+// ErrorEvent — кадр не выполнен; op — тип исходного кадра
+type ErrorEvent struct {
+	Type      string `json:"type"`
+	Op        string `json:"op"`
+	ChannelID string `json:"channelId,omitempty"`
+	Nonce     string `json:"nonce,omitempty"` // nonce исходного MESSAGE_CREATE
+	Error     string `json:"error"`
+}
+
+// RateLimitedEvent — кадр отклонён лимитом отправки; retryAfter — через сколько секунд повторить
+type RateLimitedEvent struct {
+	Type       string  `json:"type"`
+	Op         string  `json:"op"`
+	ChannelID  string  `json:"channelId,omitempty"`
+	Nonce      string  `json:"nonce,omitempty"`
+	Scope      string  `json:"scope"`
+	RetryAfter float64 `json:"retryAfter"`
+}
@@ -0,0 +1,226 @@
+package ws
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/repository"
+)
+
+const (
+	historyDefaultLimit = 50
+	historyMaxLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// HistoryQuery — параметры выборки истории канала.
+// Задаётся не больше одного из Before / After / Around / Cursor.
+type HistoryQuery struct {
+	Before string // ID сообщения: сообщения старше него
+	After  string // ID сообщения: сообщения новее него
+	Around string // ID сообщения: оно само и соседи с обеих сторон
+	Cursor string // непрозрачный курсор из предыдущей страницы
+	Limit  int    // 0 — по умолчанию
+}
+
+// HistoryPage — страница истории (новые первыми) и курсоры для продолжения.
+// Пустой курсор — в эту сторону сообщений больше нет.
+type HistoryPage struct {
+	Messages []models.Message
+	Older    string
+	Newer    string
+}
+
+// History возвращает страницу истории канала
+func History(actor Actor, channelID string, q HistoryQuery) (*HistoryPage, error) {
+	cid, err := authorizeRead(actor, channelID)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := historyLimit(q.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	set := 0
+	for _, v := range []string{q.Before, q.After, q.Around, q.Cursor} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("%w: use only one of before, after, around, cursor", ErrInvalidCursor)
+	}
+
+	var (
+		from *repository.Cursor
+		dir  = repository.Older
+	)
+	switch {
+	case q.Around != "":
+		cur, err := messageCursor(cid, "around", q.Around)
+		if err != nil {
+			return nil, err
+		}
+		return historyAround(cid, cur, limit, actor.UserID)
+	case q.Before != "":
+		cur, err := messageCursor(cid, "before", q.Before)
+		if err != nil {
+			return nil, err
+		}
+		from = &cur
+	case q.After != "":
+		cur, err := messageCursor(cid, "after", q.After)
+		if err != nil {
+			return nil, err
+		}
+		from, dir = &cur, repository.Newer
+	case q.Cursor != "":
+		cur, d, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		from, dir = &cur, d
+	}
+
+	msgs, err := repository.GetMessages(cid, from, dir, limit, actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	page := &HistoryPage{Messages: msgs}
+	full := len(msgs) == limit
+	switch {
+	case dir == repository.Older:
+		if full {
+			page.Older = olderCursor(msgs)
+		}
+		// Без точки отсчёта страница начинается с самого нового сообщения
+		if from != nil {
+			page.Newer = newerCursor(msgs, from)
+		}
+	default:
+		if full {
+			page.Newer = newerCursor(msgs, from)
+		}
+		page.Older = olderCursor(msgs)
+		if page.Older == "" {
+			page.Older = encodeCursor(repository.Older, *from)
+		}
+	}
+	return page, nil
+}
+
+// historyAround возвращает сообщение-якорь и соседей: новее — до половины limit, остальное — старше
+func historyAround(cid gocql.UUID, anchor repository.Cursor, limit int, viewerID string) (*HistoryPage, error) {
+	newerLimit := (limit - 1) / 2
+	newer, err := repository.GetMessages(cid, &anchor, repository.Newer, newerLimit, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	olderLimit := limit - len(newer)
+	from := anchor
+	from.Inclusive = true
+	older, err := repository.GetMessages(cid, &from, repository.Older, olderLimit, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &HistoryPage{Messages: append(newer, older...)}
+	if newerLimit > 0 && len(newer) == newerLimit {
+		page.Newer = newerCursor(page.Messages, &anchor)
+	}
+	if len(older) == olderLimit {
+		page.Older = olderCursor(page.Messages)
+	}
+	return page, nil
+}
+
+// historyLimit проверяет limit и ограничивает его сверху
+func historyLimit(limit int) (int, error) {
+	switch {
+	case limit == 0:
+		return historyDefaultLimit, nil
+	case limit < 0:
+		return 0, fmt.Errorf("%w: limit must be positive", ErrInvalidCursor)
+	case limit > historyMaxLimit:
+		return historyMaxLimit, nil
+	}
+	return limit, nil
+}
+
+// messageCursor переводит ID сообщения из параметра name в позицию в канале
+func messageCursor(cid gocql.UUID, name, id string) (repository.Cursor, error) {
+	mid, err := gocql.ParseUUID(id)
+	if err != nil || mid.Version() != 1 {
+		return repository.Cursor{}, fmt.Errorf("%w: %s must be a message id", ErrInvalidCursor, name)
+	}
+	return repository.CursorFor(cid, mid)
+}
+
+// olderCursor указывает на продолжение за самым старым сообщением страницы
+func olderCursor(msgs []models.Message) string {
+	if len(msgs) == 0 {
+		return ""
+	}
+	last := msgs[len(msgs)-1]
+	return encodeCursor(repository.Older, repository.Cursor{CreatedAt: last.CreatedAt, MessageID: last.MessageID})
+}
+
+// newerCursor указывает на продолжение за самым новым сообщением страницы,
+// а для пустой страницы — за from
+func newerCursor(msgs []models.Message, from *repository.Cursor) string {
+	if len(msgs) == 0 {
+		return encodeCursor(repository.Newer, repository.Cursor{CreatedAt: from.CreatedAt, MessageID: from.MessageID})
+	}
+	first := msgs[0]
+	return encodeCursor(repository.Newer, repository.Cursor{CreatedAt: first.CreatedAt, MessageID: first.MessageID})
+}
+
+// Курсор — base64url от "o|n:<messageId>:<created_at в мс>".
+// Клиенту формат не важен, он передаёт курсор обратно как есть.
+func encodeCursor(dir repository.Direction, cur repository.Cursor) string {
+	d := "o"
+	if dir == repository.Newer {
+		d = "n"
+	}
+	raw := d + ":" + cur.MessageID.String() + ":" + strconv.FormatInt(cur.CreatedAt.UnixMilli(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (repository.Cursor, repository.Direction, error) {
+	bad := fmt.Errorf("%w: malformed cursor", ErrInvalidCursor)
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return repository.Cursor{}, 0, bad
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return repository.Cursor{}, 0, bad
+	}
+	var dir repository.Direction
+	switch parts[0] {
+	case "o":
+		dir = repository.Older
+	case "n":
+		dir = repository.Newer
+	default:
+		return repository.Cursor{}, 0, bad
+	}
+	mid, err := gocql.ParseUUID(parts[1])
+	if err != nil {
+		return repository.Cursor{}, 0, bad
+	}
+	ms, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return repository.Cursor{}, 0, bad
+	}
+	return repository.Cursor{CreatedAt: time.UnixMilli(ms), MessageID: mid}, dir, nil
+}
@@ -1,16 +1,200 @@
 package ws
 
-import "sync"
+import (
+    "context"
+    "encoding/json"
+    "log"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
 
-// Hub хранит подписки по channelID
+    "github.com/yourorg/chat-service/broker"
+    "github.com/yourorg/chat-service/cluster"
+    "github.com/yourorg/chat-service/config"
+    "github.com/yourorg/chat-service/guilds"
+)
+
+// Hub хранит подписки по channelID. Одно соединение может
+// быть подписано на много каналов сразу.
+// Рассылка не блокируется: кадры кладутся в очередь клиента,
+// а отстающих клиентов обрабатывает политика policy.
+// Через broker кадры уходят на остальные реплики и приходят от них.
 type Hub struct {
     mu       sync.RWMutex
     channels map[string]map[*Client]bool
+    clients  map[*Client]map[string]bool // соединение -> его подписки
+    typing   *typingTracker
+
+    sendBuffer int
+    policy     string
+
+    broker   broker.Broker // nil — только локальная доставка
+    node     string
+    outbound chan broker.Envelope // очередь publisher: медленная шина не тормозит рассылку
+    dropped  atomic.Int64         // кадры, не попавшие в переполненную очередь
+}
+
+func NewHub(b broker.Broker) *Hub {
+    if !validPolicy(config.WSSlowPolicy) {
+        log.Fatalf("unknown WS_SLOW_POLICY %q", config.WSSlowPolicy)
+    }
+    h := &Hub{
+        channels:   make(map[string]map[*Client]bool),
+        clients:    make(map[*Client]map[string]bool),
+        typing:     newTypingTracker(),
+        sendBuffer: config.WSSendBuffer,
+        policy:     config.WSSlowPolicy,
+        broker:     b,
+        node:       broker.NewNodeID(),
+    }
+    if b != nil {
+        if err := b.Subscribe(context.Background(), h.receive); err != nil {
+            log.Fatalf("hub: broker subscribe: %v", err)
+        }
+        h.outbound = make(chan broker.Envelope, config.BrokerBuffer)
+        go h.publisher()
+    }
+    cluster.KeepPresence(h.node, h.localUsers)
+    return h
+}
+
+// receive доставляет локальным клиентам события других узлов
+func (h *Hub) receive(env broker.Envelope) {
+    if env.Node == h.node {
+        return
+    }
+    switch env.Target {
+    case broker.TargetChannel:
+        h.deliverChannel(env.ID, env.Payload)
+    case broker.TargetUser:
+        h.deliverUser(env.ID, env.Payload)
+    case broker.TargetForget:
+        var userIDs []string
+        if err := json.Unmarshal(env.Payload, &userIDs); err != nil {
+            log.Println("hub: forget:", err)
+            return
+        }
+        h.forgetUsers(userIDs)
+    }
+}
+
+// ForgetUsers сбрасывает закэшированные права пользователей на всех узлах и сразу
+// перепроверяет подписки их соединений: кик, бан или смена ролей действуют без задержки
+func (h *Hub) ForgetUsers(userIDs []string) {
+    h.forgetUsers(userIDs)
+    payload, _ := json.Marshal(userIDs)
+    h.publish(broker.TargetForget, "", payload)
+}
+
+func (h *Hub) forgetUsers(userIDs []string) {
+    guilds.ForgetUsers(userIDs)
+    forget := make(map[string]bool, len(userIDs))
+    for _, id := range userIDs {
+        forget[strings.ToLower(id)] = true
+    }
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    for c := range h.clients {
+        if forget[strings.ToLower(c.UserID)] {
+            go c.revalidate()
+        }
+    }
+}
+
+// publish ставит кадр в очередь для остальных узлов, не дожидаясь шины.
+// Если очередь переполнена, кадр теряется: publisher периодически сообщает, сколько потеряно.
+func (h *Hub) publish(target, id string, message []byte) {
+    if h.broker == nil {
+        return
+    }
+    select {
+    case h.outbound <- broker.Envelope{Node: h.node, Target: target, ID: id, Payload: message}:
+    default:
+        h.dropped.Add(1)
+    }
+}
+
+// publisher по одному отправляет кадры из очереди в шину
+func (h *Hub) publisher() {
+    ticker := time.NewTicker(time.Minute)
+    defer ticker.Stop()
+    for {
+        select {
+        case env := <-h.outbound:
+            ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+            err := h.broker.Publish(ctx, env)
+            cancel()
+            if err != nil {
+                log.Println("hub: broker publish:", err)
+            }
+        case <-ticker.C:
+            if n := h.dropped.Swap(0); n > 0 {
+                log.Printf("hub: broker queue full, dropped %d frames", n)
+            }
+        }
+    }
+}
+
+// newQueue создаёт очередь исходящих кадров для нового соединения
+func (h *Hub) newQueue() *sendQueue {
+    return newSendQueue(h.sendBuffer, h.policy)
+}
+
+// frame готовит кадр к рассылке; ключ схлопывания нужен только политике coalesce
+func (h *Hub) frame(message []byte) frame {
+    if h.policy != PolicyCoalesce {
+        return frame{data: message}
+    }
+    return frame{key: coalesceKey(message), data: message}
 }
 
-func NewHub() *Hub {
-    return &Hub{
-        channels: make(map[string]map[*Client]bool),
+// Connect учитывает новое соединение, пока без подписок.
+// Первое соединение пользователя с узлом отмечает его присутствие в кластере.
+func (h *Hub) Connect(c *Client) {
+    h.mu.Lock()
+    first := !h.connected(c.UserID)
+    if h.clients[c] == nil {
+        h.clients[c] = make(map[string]bool)
+        openConnections.Add(1)
+    }
+    h.mu.Unlock()
+    if first {
+        h.markPresence(c.UserID, true)
+    }
+}
+
+// Disconnect снимает все подписки соединения
+func (h *Hub) Disconnect(c *Client) {
+    h.mu.Lock()
+    subs, ok := h.clients[c]
+    if !ok {
+        h.mu.Unlock()
+        return
+    }
+    for channelID := range subs {
+        h.unsubscribe(channelID, c)
+    }
+    delete(h.clients, c)
+    openConnections.Add(-1)
+    last := !h.connected(c.UserID)
+    h.mu.Unlock()
+    if last {
+        h.markPresence(c.UserID, false)
+    }
+}
+
+func (h *Hub) markPresence(userID string, online bool) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    var err error
+    if online {
+        err = cluster.MarkOnline(ctx, h.node, []string{userID})
+    } else {
+        err = cluster.MarkOffline(ctx, h.node, userID)
+    }
+    if err != nil {
+        log.Println("hub: presence:", err)
     }
 }
 
@@ -21,23 +205,137 @@ func (h *Hub) Register(channelID string, c *Client) {
         h.channels[channelID] = make(map[*Client]bool)
     }
     h.channels[channelID][c] = true
+    if h.clients[c] == nil {
+        h.clients[c] = make(map[string]bool)
+    }
+    h.clients[c][channelID] = true
 }
 
 func (h *Hub) Unregister(channelID string, c *Client) {
     h.mu.Lock()
     defer h.mu.Unlock()
+    h.unsubscribe(channelID, c)
+}
+
+func (h *Hub) unsubscribe(channelID string, c *Client) {
     if clients, ok := h.channels[channelID]; ok {
         delete(clients, c)
         if len(clients) == 0 {
             delete(h.channels, channelID)
         }
     }
+    delete(h.clients[c], channelID)
+}
+
+// Subscribed сообщает, подписано ли соединение на канал
+func (h *Hub) Subscribed(c *Client, channelID string) bool {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    return h.clients[c][channelID]
+}
+
+// SubscribedChannels возвращает каналы, на которые подписано соединение
+func (h *Hub) SubscribedChannels(c *Client) []string {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    ids := make([]string, 0, len(h.clients[c]))
+    for channelID := range h.clients[c] {
+        ids = append(ids, channelID)
+    }
+    return ids
 }
 
+// Subscriptions возвращает число подписок соединения
+func (h *Hub) Subscriptions(c *Client) int {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    return len(h.clients[c])
+}
+
+// Broadcast рассылает кадр подписчикам канала на всех узлах
 func (h *Hub) Broadcast(channelID string, message []byte) {
+    h.deliverChannel(channelID, message)
+    h.publish(broker.TargetChannel, channelID, message)
+}
+
+func (h *Hub) deliverChannel(channelID string, message []byte) {
+    f := h.frame(message)
     h.mu.RLock()
     defer h.mu.RUnlock()
     for c := range h.channels[channelID] {
-        c.Send <- message
+        c.send(f)
+    }
+}
+
+// SendToUser шлёт кадр во все соединения пользователя на всех узлах
+func (h *Hub) SendToUser(userID string, message []byte) {
+    h.deliverUser(userID, message)
+    h.publish(broker.TargetUser, userID, message)
+}
+
+func (h *Hub) deliverUser(userID string, message []byte) {
+    f := h.frame(message)
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    for c := range h.clients {
+        if strings.EqualFold(c.UserID, userID) {
+            c.send(f)
+        }
+    }
+}
+
+// IsOnline сообщает, есть ли у пользователя открытое соединение с этим узлом
+func (h *Hub) IsOnline(userID string) bool {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    return h.connected(userID)
+}
+
+// connected — IsOnline под уже взятой блокировкой
+func (h *Hub) connected(userID string) bool {
+    for c := range h.clients {
+        if strings.EqualFold(c.UserID, userID) {
+            return true
+        }
+    }
+    return false
+}
+
+// localUsers возвращает пользователей, подключённых к этому узлу
+func (h *Hub) localUsers() []string {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    seen := make(map[string]bool)
+    var ids []string
+    for c := range h.clients {
+        id := strings.ToLower(c.UserID)
+        if !seen[id] {
+            seen[id] = true
+            ids = append(ids, id)
+        }
+    }
+    return ids
+}
+
+// Online возвращает, кто из userIDs подключён хоть к одному узлу кластера.
+// Без общего состояния (или если оно недоступно) видны только соединения этого узла.
+func (h *Hub) Online(userIDs []string) map[string]bool {
+    if cluster.Shared() {
+        ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+        defer cancel()
+        online, err := cluster.Online(ctx, userIDs)
+        if err == nil {
+            return online
+        }
+        log.Println("hub: presence:", err)
+    }
+    online := make(map[string]bool, len(userIDs))
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    for _, id := range userIDs {
+        if h.connected(id) {
+            online[id] = true
+        }
     }
+    return online
 }
@@ -0,0 +1,152 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/gocql/gocql"
+
+	"github.com/yourorg/chat-service/guilds"
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/repository"
+)
+
+var (
+	userMentionRe = regexp.MustCompile(`<@!?([0-9A-Za-z-]+)>`)
+	roleMentionRe = regexp.MustCompile(`<@&([0-9A-Za-z-]+)>`)
+)
+
+// parsedMentions — то, что найдено в тексте сообщения
+type parsedMentions struct {
+	users    []string
+	roles    []string
+	everyone bool
+	here     bool
+}
+
+func parseMentions(content string) parsedMentions {
+	var p parsedMentions
+	p.users = uniqueSubmatches(userMentionRe, content)
+	p.roles = uniqueSubmatches(roleMentionRe, content)
+	p.everyone = strings.Contains(content, "@everyone")
+	p.here = strings.Contains(content, "@here")
+	return p
+}
+
+func uniqueSubmatches(re *regexp.Regexp, s string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, sm := range re.FindAllStringSubmatch(s, -1) {
+		id := strings.ToLower(sm[1])
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// applyMentions заполняет в сообщении список упоминаний по его тексту.
+// @everyone и @here срабатывают, только если у автора есть MENTION_EVERYONE в канале.
+func applyMentions(actor Actor, m *models.Message) parsedMentions {
+	p := parseMentions(m.Content)
+	if (p.everyone || p.here) && checkAccess(actor, m.ChannelID, guilds.PermMentionEveryone, false) != nil {
+		p.everyone, p.here = false, false
+	}
+	m.Mentions = p.users
+	m.MentionRoles = p.roles
+	m.MentionEveryone = p.everyone || p.here
+	return p
+}
+
+// deliverMentions раскладывает сообщение во входящие упоминания участников гильдии.
+// Получают его только те, кто видит канал. Вызывается асинхронно: отправка сообщения не ждёт guild-service.
+func deliverMentions(hub *Hub, actor Actor, m *models.Message, p parsedMentions) {
+	if len(p.users) == 0 && len(p.roles) == 0 && !p.everyone && !p.here {
+		return
+	}
+
+	channelID, err := parentChannel(m.ChannelID)
+	if err != nil {
+		log.Println("mentions: resolve channel:", err)
+		return
+	}
+	ch, err := guilds.CachedChannel(actor.Token, channelID)
+	if err != nil {
+		log.Println("mentions: get channel:", err)
+		return
+	}
+	members, err := guilds.GetChannelMembers(actor.Token, channelID)
+	if err != nil {
+		log.Println("mentions: get channel members:", err)
+		return
+	}
+
+	users := make(map[string]bool)
+	for _, id := range p.users {
+		users[id] = true
+	}
+	roles := make(map[string]bool)
+	for _, id := range p.roles {
+		roles[id] = true
+	}
+
+	var targets, rest []string
+	seen := make(map[string]bool)
+	for _, mem := range members {
+		uid := strings.ToLower(mem.UserID)
+		if seen[uid] || uid == strings.ToLower(m.SenderID) {
+			continue
+		}
+		seen[uid] = true
+		hit := p.everyone || users[uid]
+		for _, r := range mem.Roles {
+			if roles[strings.ToLower(r)] {
+				hit = true
+			}
+		}
+		switch {
+		case hit:
+			targets = append(targets, uid)
+		case p.here:
+			rest = append(rest, uid)
+		}
+	}
+	// @here — только тем, кто сейчас подключён к любой реплике
+	if len(rest) > 0 {
+		online := hub.Online(rest)
+		for _, uid := range rest {
+			if online[uid] {
+				targets = append(targets, uid)
+			}
+		}
+	}
+
+	if err := repository.AddUserMentions(targets, ch.GuildID, m); err != nil {
+		log.Println("mentions: save:", err)
+	}
+}
+
+// GetMentions возвращает входящие упоминания actor, новые первыми. Доступ проверяется
+// при чтении: упоминания из каналов, которые пользователь больше не видит
+// или где не может читать историю, не показываются.
+func GetMentions(actor Actor, limit int, before *gocql.UUID) ([]models.Mention, error) {
+	visible := make(map[gocql.UUID]bool)
+	return repository.GetUserMentions(strings.ToLower(actor.UserID), limit, before, func(channelID gocql.UUID) (bool, error) {
+		if ok, seen := visible[channelID]; seen {
+			return ok, nil
+		}
+		err := authorizeWith(actor, channelID, guilds.PermReadMessageHistory)
+		switch {
+		case err == nil:
+			visible[channelID] = true
+		case errors.Is(err, ErrForbidden), errors.Is(err, guilds.ErrForbidden), errors.Is(err, guilds.ErrNotFound):
+			visible[channelID] = false
+		default:
+			return false, err
+		}
+		return visible[channelID], nil
+	})
+}
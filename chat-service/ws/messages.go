@@ -0,0 +1,297 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"github.com/yourorg/chat-service/guilds"
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/repository"
+	"github.com/yourorg/chat-service/search"
+)
+
+// Операции над сообщениями, общие для WebSocket и REST
+
+var (
+	ErrInvalidID    = errors.New("invalid id")
+	ErrEmptyContent = errors.New("content is empty")
+	ErrInvalidReply = errors.New("replyTo message not found")
+	ErrForbidden    = errors.New("forbidden")
+)
+
+// Actor — кто выполняет действие: пользователь и его токен для guild-service
+type Actor struct {
+	UserID string
+	Token  string
+}
+
+// loadMessage находит неудалённое сообщение канала
+func loadMessage(channelID, messageID string) (*models.Message, error) {
+	cid, err := gocql.ParseUUID(channelID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	mid, err := gocql.ParseUUID(messageID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	m, err := repository.GetMessage(cid, mid)
+	if err != nil {
+		return nil, err
+	}
+	if m.Deleted {
+		return nil, repository.ErrNotFound
+	}
+	return m, nil
+}
+
+// loadVisibleMessage находит неудалённое сообщение канала, доступного actor
+func loadVisibleMessage(actor Actor, channelID, messageID string) (*models.Message, error) {
+	m, err := loadMessage(channelID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(actor, m.ChannelID); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// loadOwnMessage находит сообщение и проверяет, что actor — автор или модератор гильдии
+func loadOwnMessage(actor Actor, channelID, messageID string) (*models.Message, error) {
+	m, err := loadVisibleMessage(actor, channelID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if m.SenderID == actor.UserID {
+		return m, nil
+	}
+	if err := requireModerator(actor, m.ChannelID); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// requireModerator пропускает только модераторов гильдии, которой принадлежит канал (или ветка)
+func requireModerator(actor Actor, channelID gocql.UUID) error {
+	parentID, err := parentChannel(channelID)
+	if err != nil {
+		return err
+	}
+	ok, err := guilds.CanModerate(actor.Token, parentID, actor.UserID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// memberChannels возвращает гильдию и её каналы, если actor в ней состоит
+func memberChannels(actor Actor, guildID string) (*guilds.Guild, []guilds.Channel, error) {
+	g, err := guilds.GetGuild(actor.Token, guildID)
+	if err != nil {
+		return nil, nil, err
+	}
+	ok, err := guilds.IsMember(actor.Token, g, actor.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrForbidden
+	}
+	channels, err := guilds.GetChannels(actor.Token, g.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return g, channels, nil
+}
+
+// NewMessage — данные нового сообщения от клиента
+type NewMessage struct {
+	ChannelID   string
+	Content     string
+	ReplyTo     string   // ID сообщения, на которое отвечаем
+	Attachments []string // ID ранее загруженных файлов
+	Nonce       string   // ключ идемпотентности от клиента, необязателен
+}
+
+// CreateMessage сохраняет новое сообщение и рассылает MESSAGE_CREATE.
+// ChannelID может быть ID ветки — тогда ветка заодно помечается активной.
+// Повтор с тем же nonce в пределах MESSAGE_NONCE_TTL возвращает исходное сообщение
+// с created=false и ничего не рассылает.
+func CreateMessage(hub *Hub, actor Actor, in NewMessage) (m *models.Message, created bool, err error) {
+	channelID := in.ChannelID
+	if strings.TrimSpace(in.Content) == "" && len(in.Attachments) == 0 {
+		return nil, false, ErrEmptyContent
+	}
+	if len(in.Attachments) > maxAttachments {
+		return nil, false, ErrTooManyAttachments
+	}
+	if len(in.Nonce) > maxNonceLength {
+		return nil, false, ErrInvalidNonce
+	}
+	cid, err := authorizeSend(actor, channelID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Создаём модель и сохраняем
+	m = &models.Message{
+		ChannelID: cid,
+		MessageID: gocql.TimeUUID(), // генерация UUID Cassandra
+		SenderID:  actor.UserID,
+		Content:   in.Content,
+		CreatedAt: time.Now(),
+		Nonce:     in.Nonce,
+	}
+	mentions := applyMentions(actor, m)
+	if replyTo := in.ReplyTo; replyTo != "" {
+		ref, err := loadMessage(channelID, replyTo)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) || errors.Is(err, ErrInvalidID) {
+				return nil, false, ErrInvalidReply
+			}
+			return nil, false, err
+		}
+		m.ReplyTo = &ref.MessageID
+	}
+
+	thread, err := repository.GetThread(cid)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, false, err
+	}
+
+	// nonce закрепляется до вложений: повтор не должен споткнуться о уже использованную загрузку
+	if m.Nonce != "" {
+		orig, err := claimNonce(actor, m)
+		if err != nil {
+			return nil, false, err
+		}
+		if orig != nil {
+			return orig, false, nil
+		}
+	}
+	if err := checkSendLimits(actor, cid); err != nil {
+		releaseNonce(actor, m)
+		return nil, false, err
+	}
+	if err := attachUploads(actor, m, in.Attachments); err != nil {
+		releaseNonce(actor, m)
+		return nil, false, err
+	}
+	// Медленный режим — последняя проверка: отказ выше не должен занимать интервал
+	slowmode, err := checkSlowmode(actor, cid, m.CreatedAt)
+	if err != nil {
+		releaseUploads(m)
+		releaseNonce(actor, m)
+		return nil, false, err
+	}
+	if err := repository.SaveMessage(m); err != nil {
+		if slowmode {
+			releaseSlowmode(actor, m)
+		}
+		releaseUploads(m)
+		releaseNonce(actor, m)
+		return nil, false, err
+	}
+	hub.typing.stopTyping(channelID, actor.UserID)
+	// Своё сообщение — канал прочитан
+	if err := ackMessage(hub, actor.UserID, m); err != nil {
+		log.Println("ack own message:", err)
+	}
+	parentID := channelID
+	if thread != nil {
+		touchThread(hub, thread, m.CreatedAt)
+		parentID = thread.ChannelID.String()
+	}
+	indexMessage(m, parentID)
+	go deliverMentions(hub, actor, m, mentions)
+	enqueuePreviews(m)
+
+	// Шлём назад всем
+	ev := MessageEvent{Type: EventMessageCreate, Message: m}
+	out, _ := json.Marshal(ev)
+	hub.Broadcast(channelID, out)
+	emitEvent(actor, EventMessageCreate, cid, ev)
+	return m, true, nil
+}
+
+// EditMessage меняет текст сообщения и рассылает MESSAGE_UPDATE
+func EditMessage(hub *Hub, actor Actor, channelID, messageID, content string) (*models.Message, error) {
+	m, err := loadOwnMessage(actor, channelID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(content) == "" && len(m.Attachments) == 0 {
+		return nil, ErrEmptyContent
+	}
+	m.Content = content
+	// Упоминания пересчитываются; доставка идемпотентна, повторно никого не дублирует
+	mentions := applyMentions(actor, m)
+	if err := repository.UpdateMessageContent(m, time.Now()); err != nil {
+		return nil, err
+	}
+	go deliverMentions(hub, actor, m, mentions)
+	if parentID, err := parentChannel(m.ChannelID); err != nil {
+		log.Println("search: resolve channel:", err)
+	} else {
+		indexMessage(m, parentID)
+	}
+
+	ev := MessageEvent{Type: EventMessageUpdate, Message: m}
+	out, _ := json.Marshal(ev)
+	hub.Broadcast(channelID, out)
+	emitEvent(actor, EventMessageUpdate, m.ChannelID, ev)
+	return m, nil
+}
+
+// DeleteMessage помечает сообщение удалённым и рассылает MESSAGE_DELETE
+func DeleteMessage(hub *Hub, actor Actor, channelID, messageID string) error {
+	m, err := loadOwnMessage(actor, channelID, messageID)
+	if err != nil {
+		return err
+	}
+	ev, err := removeMessage(hub, m)
+	if err != nil {
+		return err
+	}
+	emitEvent(actor, EventMessageDelete, m.ChannelID, ev)
+	return nil
+}
+
+// removeMessage удаляет уже проверенное сообщение со всеми следами и рассылает MESSAGE_DELETE.
+// Событие для outbox отправляет вызывающий: гильдию он знает лучше.
+func removeMessage(hub *Hub, m *models.Message) (MessageDeleteEvent, error) {
+	channelID := m.ChannelID.String()
+	attachments := m.Attachments
+	if err := repository.DeleteMessage(m); err != nil {
+		return MessageDeleteEvent{}, err
+	}
+	go removeUploads(attachments)
+	if err := search.RemoveMessage(m.MessageID.String()); err != nil {
+		log.Println("search: remove message:", err)
+	}
+	// Удалённое сообщение не может оставаться закреплённым
+	if unpinned, err := repository.UnpinMessage(m.ChannelID, m.MessageID); err != nil {
+		log.Println("unpin deleted message:", err)
+	} else if unpinned {
+		broadcastPinsUpdate(hub, m.ChannelID)
+	}
+
+	ev := MessageDeleteEvent{
+		Type:      EventMessageDelete,
+		ChannelID: m.ChannelID.String(),
+		MessageID: m.MessageID.String(),
+	}
+	out, _ := json.Marshal(ev)
+	hub.Broadcast(channelID, out)
+	return ev, nil
+}
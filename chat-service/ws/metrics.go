@@ -0,0 +1,11 @@
+package ws
+
+import "expvar"
+
+// Метрики WebSocket, отдаются через /debug/vars
+var (
+	openConnections = expvar.NewInt("ws_connections")
+	framesSent      = expvar.NewInt("ws_frames_sent")
+	droppedFrames   = expvar.NewMap("ws_frames_dropped") // по политике: drop_oldest, coalesce, disconnect
+	slowDisconnects = expvar.NewInt("ws_slow_disconnects")
+)
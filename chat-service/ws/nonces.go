@@ -0,0 +1,50 @@
+package ws
+
+import (
+	"errors"
+	"log"
+
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/repository"
+)
+
+// maxNonceLength — ограничение на длину nonce от клиента
+const maxNonceLength = 64
+
+var (
+	ErrInvalidNonce  = errors.New("nonce is too long")
+	ErrNonceConflict = errors.New("nonce is already used by another message")
+)
+
+// claimNonce закрепляет nonce за новым сообщением m.
+// Если с этим nonce пользователь уже отправлял сообщение в канал, возвращает исходное сообщение.
+func claimNonce(actor Actor, m *models.Message) (*models.Message, error) {
+	claimed, prevChannel, prevMessage, err := repository.ClaimNonce(actor.UserID, m.Nonce, m.ChannelID, m.MessageID, config.NonceTTL)
+	if err != nil || claimed {
+		return nil, err
+	}
+	if prevChannel != m.ChannelID {
+		return nil, ErrNonceConflict
+	}
+	orig, err := repository.GetMessage(prevChannel, prevMessage)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Первая отправка ещё не сохранила сообщение (или не смогла)
+		return nil, ErrNonceConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	orig.Nonce = m.Nonce
+	return orig, nil
+}
+
+// releaseNonce освобождает nonce несохранённого сообщения, чтобы повтор мог пройти
+func releaseNonce(actor Actor, m *models.Message) {
+	if m.Nonce == "" {
+		return
+	}
+	if err := repository.ReleaseNonce(actor.UserID, m.Nonce); err != nil {
+		log.Println("release nonce:", err)
+	}
+}
@@ -0,0 +1,34 @@
+package ws
+
+import (
+	"log"
+
+	"github.com/gocql/gocql"
+
+	"github.com/yourorg/chat-service/guilds"
+	"github.com/yourorg/chat-service/outbox"
+)
+
+// emitEvent публикует событие о сообщении для realtime-service.
+// payload — тот же кадр, что уходит в WS. Ошибки не срывают операцию:
+// сообщение уже сохранено и разослано по WS.
+func emitEvent(actor Actor, evType string, channelID gocql.UUID, payload interface{}) {
+	parentID, err := parentChannel(channelID)
+	if err != nil {
+		log.Println("outbox: resolve channel:", err)
+		return
+	}
+	guildID, err := guilds.GuildIDForChannel(actor.Token, parentID)
+	if err != nil {
+		log.Println("outbox: resolve guild:", err)
+		return
+	}
+	emitGuildEvent(guildID, evType, channelID, payload)
+}
+
+// emitGuildEvent — то же, когда гильдия уже известна
+func emitGuildEvent(guildID, evType string, channelID gocql.UUID, payload interface{}) {
+	if err := outbox.Emit(evType, guildID, channelID.String(), payload); err != nil {
+		log.Println("outbox: emit:", err)
+	}
+}
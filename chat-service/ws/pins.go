@@ -0,0 +1,107 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/repository"
+)
+
+var ErrPinLimit = errors.New("pin limit reached")
+
+// PinMessage закрепляет сообщение, рассылает CHANNEL_PINS_UPDATE и
+// добавляет в канал системное сообщение о закреплении
+func PinMessage(hub *Hub, actor Actor, channelID, messageID string) error {
+	m, err := loadVisibleMessage(actor, channelID, messageID)
+	if err != nil {
+		return err
+	}
+	if m.Kind != "" {
+		// системные сообщения не закрепляются
+		return ErrInvalidID
+	}
+	if err := requireModerator(actor, m.ChannelID); err != nil {
+		return err
+	}
+
+	n, err := repository.CountPins(m.ChannelID)
+	if err != nil {
+		return err
+	}
+	if n >= config.PinLimit {
+		return ErrPinLimit
+	}
+
+	now := time.Now()
+	applied, err := repository.PinMessage(m.ChannelID, m.MessageID, actor.UserID, now)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return nil
+	}
+	broadcastPinsUpdate(hub, m.ChannelID)
+
+	sys := &models.Message{
+		ChannelID: m.ChannelID,
+		MessageID: gocql.TimeUUID(),
+		SenderID:  actor.UserID,
+		CreatedAt: now,
+		ReplyTo:   &m.MessageID,
+		Kind:      models.MessageKindPinned,
+	}
+	if err := repository.SaveMessage(sys); err != nil {
+		log.Println("save pin system message:", err)
+		return nil
+	}
+	ev := MessageEvent{Type: EventMessageCreate, Message: sys}
+	out, _ := json.Marshal(ev)
+	hub.Broadcast(channelID, out)
+	emitEvent(actor, EventMessageCreate, sys.ChannelID, ev)
+	return nil
+}
+
+// UnpinMessage открепляет сообщение и рассылает CHANNEL_PINS_UPDATE
+func UnpinMessage(hub *Hub, actor Actor, channelID, messageID string) error {
+	cid, err := gocql.ParseUUID(channelID)
+	if err != nil {
+		return ErrInvalidID
+	}
+	mid, err := gocql.ParseUUID(messageID)
+	if err != nil {
+		return ErrInvalidID
+	}
+	if err := requireModerator(actor, cid); err != nil {
+		return err
+	}
+
+	applied, err := repository.UnpinMessage(cid, mid)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return repository.ErrNotFound
+	}
+	broadcastPinsUpdate(hub, cid)
+	return nil
+}
+
+// ListPins возвращает закреплённые сообщения канала
+func ListPins(actor Actor, channelID string) ([]models.Pin, error) {
+	cid, err := authorizeRead(actor, channelID)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetPins(cid, actor.UserID)
+}
+
+func broadcastPinsUpdate(hub *Hub, channelID gocql.UUID) {
+	out, _ := json.Marshal(PinsUpdateEvent{Type: EventPinsUpdate, ChannelID: channelID.String()})
+	hub.Broadcast(channelID.String(), out)
+}
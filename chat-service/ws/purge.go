@@ -0,0 +1,70 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"github.com/yourorg/chat-service/repository"
+)
+
+// purgePage — сколько сообщений читается за раз при чистке
+const purgePage = 100
+
+// PurgeMessages удаляет сообщения userID новее since в каналах channelIDs гильдии
+// и их ветках. Так guild-service чистит историю забаненного; права он проверил сам.
+// Возвращает число удалённых сообщений.
+func PurgeMessages(hub *Hub, guildID, userID string, channelIDs []string, since time.Time) (int, error) {
+	deleted := 0
+	for _, raw := range channelIDs {
+		cid, err := gocql.ParseUUID(raw)
+		if err != nil {
+			return deleted, ErrInvalidID
+		}
+		ids := []gocql.UUID{cid}
+		threads, err := repository.GetThreads(cid)
+		if err != nil {
+			return deleted, err
+		}
+		for _, t := range threads {
+			ids = append(ids, t.ThreadID)
+		}
+		for _, id := range ids {
+			n, err := purgeChannel(hub, guildID, id, userID, since)
+			deleted += n
+			if err != nil {
+				return deleted, err
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// purgeChannel листает историю канала от since к новым и удаляет сообщения userID
+func purgeChannel(hub *Hub, guildID string, channelID gocql.UUID, userID string, since time.Time) (int, error) {
+	from := &repository.Cursor{CreatedAt: since, MessageID: gocql.MinTimeUUID(since), Inclusive: true}
+	deleted := 0
+	for {
+		msgs, err := repository.GetMessages(channelID, from, repository.Newer, purgePage, "")
+		if err != nil || len(msgs) == 0 {
+			return deleted, err
+		}
+		for i := range msgs {
+			if msgs[i].SenderID != userID {
+				continue
+			}
+			m := msgs[i]
+			ev, err := removeMessage(hub, &m)
+			if err != nil {
+				return deleted, err
+			}
+			emitGuildEvent(guildID, EventMessageDelete, m.ChannelID, ev)
+			deleted++
+		}
+		// страница упорядочена от новых к старым
+		from = &repository.Cursor{CreatedAt: msgs[0].CreatedAt, MessageID: msgs[0].MessageID}
+		if len(msgs) < purgePage {
+			return deleted, nil
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+// Политики для клиентов, которые не успевают читать кадры
+const (
+	PolicyDropOldest = "drop_oldest" // выбросить самый старый кадр очереди
+	PolicyDisconnect = "disconnect"  // закрыть соединение, клиент переподключится
+	PolicyCoalesce   = "coalesce"    // заменить устаревший кадр о том же объекте, иначе как drop_oldest
+)
+
+func validPolicy(p string) bool {
+	return p == PolicyDropOldest || p == PolicyDisconnect || p == PolicyCoalesce
+}
+
+// frame — исходящий кадр; key непустой у кадров, которые можно схлопнуть
+type frame struct {
+	key  string
+	data []byte
+}
+
+// sendQueue — ограниченная очередь исходящих кадров клиента.
+// push никогда не блокируется, writePump забирает кадры пачкой.
+type sendQueue struct {
+	mu     sync.Mutex
+	frames []frame
+	limit  int
+	policy string
+	ready  chan struct{} // сигнал writePump, что в очереди что-то есть
+}
+
+func newSendQueue(limit int, policy string) *sendQueue {
+	return &sendQueue{
+		limit:  max(limit, 1),
+		policy: policy,
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// push ставит кадр в очередь. false — очередь переполнена и по политике
+// клиента нужно отключить.
+func (q *sendQueue) push(f frame) bool {
+	q.mu.Lock()
+	if len(q.frames) >= q.limit {
+		switch q.policy {
+		case PolicyDisconnect:
+			q.mu.Unlock()
+			droppedFrames.Add(PolicyDisconnect, 1)
+			return false
+		case PolicyCoalesce:
+			if i := q.find(f.key); i >= 0 {
+				q.frames[i] = f
+				q.mu.Unlock()
+				droppedFrames.Add(PolicyCoalesce, 1)
+				return true
+			}
+		}
+		q.frames = q.frames[1:]
+		droppedFrames.Add(PolicyDropOldest, 1)
+	}
+	q.frames = append(q.frames, f)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (q *sendQueue) find(key string) int {
+	if key == "" {
+		return -1
+	}
+	for i := range q.frames {
+		if q.frames[i].key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// drain забирает все накопившиеся кадры
+func (q *sendQueue) drain() []frame {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := q.frames
+	q.frames = nil
+	return out
+}
+
+// coalesceKey — ключ объекта, о котором кадр: более новый кадр с тем же ключом
+// полностью заменяет старый. У MESSAGE_CREATE и реакций ключа нет — их терять нельзя.
+func coalesceKey(message []byte) string {
+	var f struct {
+		Type      string `json:"type"`
+		ChannelID string `json:"channelId"`
+		MessageID string `json:"messageId"`
+		ThreadID  string `json:"threadId"`
+		UserID    string `json:"userId"`
+	}
+	if err := json.Unmarshal(message, &f); err != nil {
+		log.Println("ws coalesce key:", err)
+		return ""
+	}
+	switch f.Type {
+	case EventMessageUpdate:
+		return f.Type + ":" + f.MessageID
+	case EventTypingStart, EventTypingStop:
+		return "TYPING:" + f.ChannelID + ":" + f.UserID
+	case EventPinsUpdate:
+		return f.Type + ":" + f.ChannelID
+	case EventThreadUpdate:
+		return f.Type + ":" + f.ThreadID
+	}
+	return ""
+}
@@ -0,0 +1,93 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/guilds"
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/ratelimit"
+	"github.com/yourorg/chat-service/repository"
+)
+
+// Области лимита отправки сообщений
+const (
+	LimitUser     = "user"     // пользователь пишет слишком часто во все каналы
+	LimitChannel  = "channel"  // в канал пишут слишком часто все вместе
+	LimitSlowmode = "slowmode" // медленный режим канала
+)
+
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitError — отправка отклонена лимитом; повторить можно через RetryAfter
+type RateLimitError struct {
+	Scope      string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string { return "rate limited (" + e.Scope + ")" }
+
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
+
+// Token bucket'ы живут в памяти реплики: при нескольких репликах лимит на пользователя
+// мягче в число реплик. Медленный режим общий — он хранится в Cassandra.
+var (
+	userLimiter    = ratelimit.New(config.RateUserBurst, config.RateUserRefill)
+	channelLimiter = ratelimit.New(config.RateChannelBurst, config.RateChannelRefill)
+)
+
+// checkSendLimits пропускает сообщение actor в канал cid или возвращает *RateLimitError.
+// Медленный режим проверяется отдельно, последним перед сохранением (checkSlowmode).
+func checkSendLimits(actor Actor, cid gocql.UUID) error {
+	if ok, retry := userLimiter.Allow(actor.UserID); !ok {
+		return &RateLimitError{Scope: LimitUser, RetryAfter: retry}
+	}
+	if ok, retry := channelLimiter.Allow(cid.String()); !ok {
+		return &RateLimitError{Scope: LimitChannel, RetryAfter: retry}
+	}
+	return nil
+}
+
+// checkSlowmode применяет медленный режим канала; ветка живёт по режиму родителя.
+// Модераторов медленный режим не касается. claimed — отметка о сообщении поставлена:
+// если сообщение не сохранится, её снимает releaseSlowmode.
+func checkSlowmode(actor Actor, cid gocql.UUID, now time.Time) (claimed bool, err error) {
+	parentID, err := parentChannel(cid)
+	if err != nil {
+		return false, err
+	}
+	ch, err := guilds.CachedChannel(actor.Token, parentID)
+	if err != nil {
+		return false, err
+	}
+	if ch.SlowmodeSeconds <= 0 {
+		return false, nil
+	}
+	interval := time.Duration(ch.SlowmodeSeconds) * time.Second
+
+	claimed, prev, err := repository.ClaimSlowmode(cid, actor.UserID, now, interval)
+	if err != nil || claimed {
+		return claimed, err
+	}
+	if ok, err := guilds.CanModerate(actor.Token, parentID, actor.UserID); err != nil {
+		return false, err
+	} else if ok {
+		return false, nil
+	}
+	retry := prev.Add(interval).Sub(now)
+	if retry < 0 {
+		retry = 0
+	}
+	return false, &RateLimitError{Scope: LimitSlowmode, RetryAfter: retry}
+}
+
+// releaseSlowmode снимает отметку checkSlowmode, когда сообщение так и не сохранилось
+func releaseSlowmode(actor Actor, m *models.Message) {
+	if err := repository.ReleaseSlowmode(m.ChannelID, actor.UserID, m.CreatedAt); err != nil {
+		log.Println("release slowmode:", err)
+	}
+}
@@ -0,0 +1,75 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/yourorg/chat-service/guilds"
+	"github.com/yourorg/chat-service/repository"
+)
+
+// maxEmojiLen — с запасом на составные эмодзи и кастомные name:id
+const maxEmojiLen = 64
+
+var ErrInvalidEmoji = errors.New("invalid emoji")
+
+func validEmoji(emoji string) bool {
+	if emoji == "" || len(emoji) > maxEmojiLen {
+		return false
+	}
+	return !strings.ContainsFunc(emoji, unicode.IsSpace)
+}
+
+// AddReaction ставит реакцию actor на сообщение и рассылает REACTION_ADD
+func AddReaction(hub *Hub, actor Actor, channelID, messageID, emoji string) error {
+	return react(hub, actor, channelID, messageID, emoji, true)
+}
+
+// RemoveReaction снимает реакцию actor и рассылает REACTION_REMOVE
+func RemoveReaction(hub *Hub, actor Actor, channelID, messageID, emoji string) error {
+	return react(hub, actor, channelID, messageID, emoji, false)
+}
+
+func react(hub *Hub, actor Actor, channelID, messageID, emoji string, add bool) error {
+	if !validEmoji(emoji) {
+		return ErrInvalidEmoji
+	}
+	m, err := loadVisibleMessage(actor, channelID, messageID)
+	if err != nil {
+		return err
+	}
+	// Снять свою реакцию можно и без ADD_REACTIONS
+	if add {
+		if err := authorizeWith(actor, m.ChannelID, guilds.PermAddReactions); err != nil {
+			return err
+		}
+	}
+
+	var applied bool
+	evType := EventReactionAdd
+	if add {
+		applied, err = repository.AddReaction(m.ChannelID, m.MessageID, emoji, actor.UserID)
+	} else {
+		evType = EventReactionRemove
+		applied, err = repository.RemoveReaction(m.ChannelID, m.MessageID, emoji, actor.UserID)
+	}
+	if err != nil {
+		return err
+	}
+	// Повторный PUT/DELETE ничего не меняет — и рассылать нечего
+	if !applied {
+		return nil
+	}
+
+	out, _ := json.Marshal(ReactionEvent{
+		Type:      evType,
+		ChannelID: m.ChannelID.String(),
+		MessageID: m.MessageID.String(),
+		UserID:    actor.UserID,
+		Emoji:     emoji,
+	})
+	hub.Broadcast(channelID, out)
+	return nil
+}
@@ -0,0 +1,76 @@
+package ws
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/repository"
+)
+
+// maxUnreadCount — дальше непрочитанные не считаем, клиенту хватит «99+»
+const maxUnreadCount = 100
+
+// AckMessage отмечает канал прочитанным до сообщения и рассылает
+// MESSAGE_ACK остальным сессиям пользователя
+func AckMessage(hub *Hub, actor Actor, channelID, messageID string) error {
+	m, err := loadVisibleMessage(actor, channelID, messageID)
+	if err != nil {
+		return err
+	}
+	return ackMessage(hub, actor.UserID, m)
+}
+
+func ackMessage(hub *Hub, userID string, m *models.Message) error {
+	if err := repository.SaveReadState(userID, m); err != nil {
+		return err
+	}
+	out, _ := json.Marshal(AckEvent{
+		Type:      EventMessageAck,
+		ChannelID: m.ChannelID.String(),
+		MessageID: m.MessageID.String(),
+	})
+	hub.SendToUser(userID, out)
+	return nil
+}
+
+// GuildReadStates возвращает непрочитанные и упоминания по всем текстовым каналам гильдии
+func GuildReadStates(actor Actor, guildID string) ([]models.ReadState, error) {
+	g, channels, err := memberChannels(actor, guildID)
+	if err != nil {
+		return nil, err
+	}
+	states, err := repository.GetReadStates(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]models.ReadState, 0, len(channels))
+	readAt := make(map[gocql.UUID]time.Time, len(channels))
+	for _, ch := range channels {
+		cid, err := gocql.ParseUUID(ch.ID)
+		if err != nil || ch.Type == "VOICE" {
+			continue
+		}
+		rs, ok := states[cid]
+		if !ok {
+			rs = models.ReadState{ChannelID: cid}
+		}
+		if rs.UnreadCount, err = repository.CountUnread(cid, rs.LastReadAt, actor.UserID, maxUnreadCount); err != nil {
+			return nil, err
+		}
+		readAt[cid] = rs.LastReadAt
+		list = append(list, rs)
+	}
+
+	mentions, err := repository.CountMentions(actor.UserID, g.ID, readAt)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].MentionCount = mentions[list[i].ChannelID]
+	}
+	return list, nil
+}
@@ -0,0 +1,133 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gocql/gocql"
+
+	"github.com/yourorg/chat-service/guilds"
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/repository"
+	"github.com/yourorg/chat-service/search"
+)
+
+const (
+	searchDefaultLimit = 25
+	searchMaxLimit     = 100
+)
+
+// SearchResult — страница результатов поиска
+type SearchResult struct {
+	Total    uint64           `json:"total"`
+	Messages []models.Message `json:"messages"`
+}
+
+// SearchMessages ищет по сообщениям гильдии. Выдача ограничена каналами,
+// где actor может читать историю; сообщения берутся из Cassandra, так что правки
+// и удаления после индексации учитываются.
+func SearchMessages(actor Actor, guildID, raw string, limit, offset int) (*SearchResult, error) {
+	q, err := search.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = searchDefaultLimit
+	}
+	limit = min(limit, searchMaxLimit)
+	offset = max(offset, 0)
+
+	_, channels, err := memberChannels(actor, guildID)
+	if err != nil {
+		return nil, err
+	}
+	channels, err = readableChannels(actor, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	parents, threads := searchScope(channels, q.In)
+	hits, total, err := search.Search(q, parents, threads, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &SearchResult{Total: total, Messages: []models.Message{}}
+	for _, h := range hits {
+		m, err := loadMessage(h.ChannelID, h.MessageID)
+		if errors.Is(err, repository.ErrNotFound) || errors.Is(err, ErrInvalidID) {
+			// устаревшая запись индекса
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res.Messages = append(res.Messages, *m)
+	}
+	return res, nil
+}
+
+// readableChannels оставляет каналы, где actor может читать историю
+func readableChannels(actor Actor, channels []guilds.Channel) ([]guilds.Channel, error) {
+	out := make([]guilds.Channel, 0, len(channels))
+	for _, ch := range channels {
+		perms, err := guilds.ChannelPermissions(actor.Token, ch.ID, actor.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if perms&guilds.PermReadMessageHistory != 0 {
+			out = append(out, ch)
+		}
+	}
+	return out, nil
+}
+
+// searchScope превращает фильтры in: в область поиска. Без фильтров —
+// все текстовые каналы гильдии; in: принимает ID или имя канала, а также ID ветки.
+func searchScope(channels []guilds.Channel, in []string) (parents, threads []string) {
+	allowed := make(map[string]bool)
+	for _, ch := range channels {
+		if ch.Type == "VOICE" {
+			continue
+		}
+		allowed[ch.ID] = true
+		if len(in) == 0 {
+			parents = append(parents, ch.ID)
+		}
+	}
+	for _, ref := range in {
+		found := false
+		for _, ch := range channels {
+			if allowed[ch.ID] && (ch.ID == ref || strings.EqualFold(ch.Name, ref)) {
+				parents = append(parents, ch.ID)
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+		tid, err := gocql.ParseUUID(ref)
+		if err != nil {
+			continue
+		}
+		t, err := repository.GetThread(tid)
+		if err != nil {
+			if !errors.Is(err, repository.ErrNotFound) {
+				log.Println("search: get thread:", err)
+			}
+			continue
+		}
+		if allowed[t.ChannelID.String()] {
+			threads = append(threads, t.ThreadID.String())
+		}
+	}
+	return parents, threads
+}
+
+// indexMessage обновляет сообщение в поисковом индексе; ошибки только логируются
+func indexMessage(m *models.Message, parentID string) {
+	if err := search.IndexMessage(m, parentID); err != nil {
+		log.Println("search: index message:", err)
+	}
+}
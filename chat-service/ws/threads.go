@@ -0,0 +1,231 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gocql/gocql"
+
+	"github.com/yourorg/chat-service/cluster"
+	"github.com/yourorg/chat-service/guilds"
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/repository"
+)
+
+const (
+	maxThreadName             = 100
+	defaultAutoArchiveMinutes = 24 * 60
+)
+
+// Допустимые сроки автоархивации веток, в минутах
+var autoArchiveMinutes = map[int]bool{60: true, 24 * 60: true, 3 * 24 * 60: true, 7 * 24 * 60: true}
+
+var (
+	ErrInvalidThread = errors.New("invalid thread parameters")
+	ErrNestedThread  = errors.New("threads cannot be nested")
+)
+
+// CreateThread открывает ветку на сообщении канала и рассылает THREAD_CREATE
+func CreateThread(hub *Hub, actor Actor, channelID, messageID, name string, autoArchive int) (*models.Thread, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxThreadName {
+		return nil, ErrInvalidThread
+	}
+	if autoArchive == 0 {
+		autoArchive = defaultAutoArchiveMinutes
+	}
+	if !autoArchiveMinutes[autoArchive] {
+		return nil, ErrInvalidThread
+	}
+
+	parent, err := loadVisibleMessage(actor, channelID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repository.GetThread(parent.ChannelID); err == nil {
+		return nil, ErrNestedThread
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	now := time.Now()
+	t := &models.Thread{
+		ThreadID:           gocql.TimeUUID(),
+		ChannelID:          parent.ChannelID,
+		ParentMessageID:    parent.MessageID,
+		Name:               name,
+		CreatorID:          actor.UserID,
+		CreatedAt:          now,
+		LastMessageAt:      now,
+		AutoArchiveMinutes: autoArchive,
+	}
+	if err := repository.CreateThread(parent, t); err != nil {
+		return nil, err
+	}
+
+	broadcastThread(hub, EventThreadCreate, t)
+	return t, nil
+}
+
+// ListThreads возвращает ветки канала; archived фильтрует по состоянию, nil — все
+func ListThreads(actor Actor, channelID string, archived *bool) ([]models.Thread, error) {
+	cid, err := authorizeID(actor, channelID)
+	if err != nil {
+		return nil, err
+	}
+	all, err := repository.GetThreads(cid)
+	if err != nil {
+		return nil, err
+	}
+	if archived == nil {
+		return all, nil
+	}
+	list := make([]models.Thread, 0, len(all))
+	for _, t := range all {
+		if t.Archived == *archived {
+			list = append(list, t)
+		}
+	}
+	return list, nil
+}
+
+// ThreadPatch — изменяемые поля ветки; nil означает «не менять»
+type ThreadPatch struct {
+	Name     *string `json:"name"`
+	Archived *bool   `json:"archived"`
+}
+
+// UpdateThread переименовывает или (раз)архивирует ветку.
+// Разрешено создателю ветки и модераторам гильдии.
+func UpdateThread(hub *Hub, actor Actor, channelID, threadID string, patch ThreadPatch) (*models.Thread, error) {
+	tid, err := gocql.ParseUUID(threadID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	t, err := repository.GetThread(tid)
+	if err != nil {
+		return nil, err
+	}
+	if t.ChannelID.String() != strings.ToLower(channelID) {
+		return nil, repository.ErrNotFound
+	}
+	if err := authorize(actor, t.ChannelID); err != nil {
+		return nil, err
+	}
+
+	if t.CreatorID != actor.UserID {
+		ok, err := guilds.CanModerate(actor.Token, channelID, actor.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrForbidden
+		}
+	}
+
+	if patch.Name != nil {
+		name := strings.TrimSpace(*patch.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxThreadName {
+			return nil, ErrInvalidThread
+		}
+		t.Name = name
+	}
+	if patch.Archived != nil {
+		setArchived(t, *patch.Archived, time.Now())
+	}
+	if err := repository.UpdateThread(t); err != nil {
+		return nil, err
+	}
+
+	broadcastThread(hub, EventThreadUpdate, t)
+	return t, nil
+}
+
+func setArchived(t *models.Thread, archived bool, now time.Time) {
+	if archived == t.Archived {
+		return
+	}
+	t.Archived = archived
+	if archived {
+		t.ArchivedAt = &now
+	} else {
+		// Разархивированная ветка снова отсчитывает неактивность с нуля
+		t.ArchivedAt = nil
+		t.LastMessageAt = now
+	}
+}
+
+// touchThread отмечает новое сообщение в ветке; архивная ветка при этом оживает
+func touchThread(hub *Hub, t *models.Thread, at time.Time) {
+	if !t.Archived {
+		if err := repository.TouchThread(t, at); err != nil {
+			log.Println("touch thread:", err)
+		}
+		return
+	}
+	setArchived(t, false, at)
+	if err := repository.UpdateThread(t); err != nil {
+		log.Println("unarchive thread:", err)
+		return
+	}
+	broadcastThread(hub, EventThreadUpdate, t)
+}
+
+// parentChannel возвращает ID настоящего канала: для ветки — её родителя.
+// guild-service про ветки не знает, поэтому права проверяются по родителю.
+func parentChannel(channelID gocql.UUID) (string, error) {
+	t, err := repository.GetThread(channelID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return channelID.String(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return t.ChannelID.String(), nil
+}
+
+// broadcastThread шлёт событие ветки и в родительский канал, и подписчикам самой ветки
+func broadcastThread(hub *Hub, evType string, t *models.Thread) {
+	out, _ := json.Marshal(ThreadEvent{Type: evType, Thread: t})
+	hub.Broadcast(t.ChannelID.String(), out)
+	hub.Broadcast(t.ThreadID.String(), out)
+}
+
+// RunThreadArchiver периодически архивирует ветки без активности.
+// Запускается на каждой реплике, но за период работает только одна — взявшая блокировку.
+func RunThreadArchiver(hub *Hub, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ok, err := cluster.TryLock(ctx, "thread-archiver", every*9/10)
+		cancel()
+		if err != nil {
+			log.Println("thread archiver: lock:", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		now := time.Now()
+		err = repository.ForEachActiveThread(func(t *models.Thread) {
+			if !t.Inactive(now) {
+				return
+			}
+			setArchived(t, true, now)
+			if err := repository.UpdateThread(t); err != nil {
+				log.Println("archive thread:", err)
+				return
+			}
+			broadcastThread(hub, EventThreadUpdate, t)
+		})
+		if err != nil {
+			log.Println("thread archiver:", err)
+		}
+	}
+}
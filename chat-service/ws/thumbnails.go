@@ -0,0 +1,185 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"path"
+
+	"github.com/gocql/gocql"
+	"golang.org/x/image/draw"
+
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/models"
+	"github.com/yourorg/chat-service/repository"
+	"github.com/yourorg/chat-service/storage"
+)
+
+// Превью картинок строятся в фоне: сообщение уходит сразу,
+// а когда превью готово, клиенты получают MESSAGE_UPDATE.
+
+// maxPreviewPixels защищает от «бомб» — картинок с огромным разрешением
+const maxPreviewPixels = 50_000_000
+
+var previewTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true, // превью — первый кадр
+	"image/webp": true,
+}
+
+type previewJob struct {
+	channelID gocql.UUID
+	messageID gocql.UUID
+}
+
+var previewQueue = make(chan previewJob, 256)
+
+// StartThumbnailer запускает воркеры генерации превью
+func StartThumbnailer(hub *Hub, workers int) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range previewQueue {
+				processPreviews(hub, job)
+			}
+		}()
+	}
+}
+
+// enqueuePreviews ставит сообщение в очередь, если в нём есть картинки.
+// Одно задание на сообщение: так вложения одного сообщения не перезаписывают друг друга.
+func enqueuePreviews(m *models.Message) {
+	need := false
+	for _, a := range m.Attachments {
+		if previewTypes[a.ContentType] {
+			need = true
+		}
+	}
+	if !need {
+		return
+	}
+	select {
+	case previewQueue <- previewJob{channelID: m.ChannelID, messageID: m.MessageID}:
+	default:
+		log.Printf("preview queue full, skipping message %s", m.MessageID)
+	}
+}
+
+func processPreviews(hub *Hub, job previewJob) {
+	m, err := repository.GetMessage(job.channelID, job.messageID)
+	if err != nil {
+		log.Println("preview: load message:", err)
+		return
+	}
+	if m.Deleted {
+		return
+	}
+
+	changed := false
+	for i := range m.Attachments {
+		a := &m.Attachments[i]
+		if !previewTypes[a.ContentType] || a.ThumbnailKey != "" {
+			continue
+		}
+		if err := buildPreview(a); err != nil {
+			log.Printf("preview: attachment %s: %v", a.ID, err)
+			continue
+		}
+		changed = true
+	}
+	if !changed {
+		return
+	}
+
+	if err := repository.UpdateMessageAttachments(m); err != nil {
+		log.Println("preview: save message:", err)
+		return
+	}
+	out, _ := json.Marshal(MessageEvent{Type: EventMessageUpdate, Message: m})
+	hub.Broadcast(m.ChannelID.String(), out)
+}
+
+// buildPreview строит превью вложения, кладёт его рядом с оригиналом
+// и дописывает в a размеры и ключ превью
+func buildPreview(a *models.Attachment) error {
+	ctx := context.Background()
+	u, err := repository.GetUpload(a.ID)
+	if err != nil {
+		return err
+	}
+
+	r, err := storage.Default.Open(ctx, u.Key)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	if cfg.Width*cfg.Height > maxPreviewPixels {
+		return errors.New("image too large for preview")
+	}
+	src, _, err := image.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return err
+	}
+
+	b := src.Bounds()
+	u.Width, u.Height = b.Dx(), b.Dy()
+	w, h := fitInto(u.Width, u.Height, config.ThumbnailSize)
+
+	if w == u.Width && h == u.Height && a.ContentType != "image/gif" && a.ContentType != "image/webp" {
+		// Картинка и так маленькая — превью служит сам оригинал
+		u.ThumbnailKey = u.Key
+	} else {
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+
+		var out bytes.Buffer
+		name, contentType := "thumbnail.jpg", "image/jpeg"
+		if dst.Opaque() {
+			err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: 85})
+		} else {
+			name, contentType = "thumbnail.png", "image/png"
+			err = png.Encode(&out, dst)
+		}
+		if err != nil {
+			return err
+		}
+
+		key := path.Join(path.Dir(u.Key), name)
+		if err := storage.Default.Put(ctx, key, &out, int64(out.Len()), contentType); err != nil {
+			return err
+		}
+		u.ThumbnailKey = key
+	}
+
+	if err := repository.SetUploadPreview(u); err != nil {
+		return err
+	}
+	a.Width, a.Height, a.ThumbnailKey = u.Width, u.Height, u.ThumbnailKey
+	return nil
+}
+
+// fitInto уменьшает размеры с сохранением пропорций, чтобы они влезли в квадрат bound×bound
+func fitInto(w, h, bound int) (int, int) {
+	if w <= bound && h <= bound {
+		return w, h
+	}
+	if w >= h {
+		return bound, max(1, h*bound/w)
+	}
+	return max(1, w*bound/h), bound
+}
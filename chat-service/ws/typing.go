@@ -0,0 +1,123 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Индикатор «печатает…». Ничего не сохраняется: сервер помнит, кто печатает,
+// пока идут TYPING_START, и сам рассылает TYPING_STOP, когда они прекращаются
+// или соединение закрывается.
+
+const (
+	typingTimeout  = 10 * time.Second // без нового TYPING_START индикатор гаснет
+	typingThrottle = 5 * time.Second  // чаще одного TYPING_START от пользователя в канал не рассылаем
+)
+
+type typingKey struct {
+	channelID string
+	userID    string
+}
+
+type typingState struct {
+	client   *Client // соединение, из которого печатают
+	timer    *time.Timer
+	lastSent time.Time
+}
+
+type typingTracker struct {
+	mu     sync.Mutex
+	active map[typingKey]*typingState
+}
+
+func newTypingTracker() *typingTracker {
+	return &typingTracker{active: make(map[typingKey]*typingState)}
+}
+
+// StartTyping отмечает, что клиент печатает в канале
+func StartTyping(hub *Hub, c *Client, channelID string) error {
+	if _, err := gocql.ParseUUID(channelID); err != nil {
+		return ErrInvalidID
+	}
+	t := hub.typing
+	key := typingKey{channelID: channelID, userID: c.UserID}
+	now := time.Now()
+
+	t.mu.Lock()
+	st, ok := t.active[key]
+	// Stop == false — таймер уже сработал и expire ждёт блокировку: заводим новое состояние
+	if ok && st.timer.Stop() {
+		st.client = c
+		st.timer.Reset(typingTimeout)
+		if now.Sub(st.lastSent) < typingThrottle {
+			// индикатор и так горит, просто продлеваем
+			t.mu.Unlock()
+			return nil
+		}
+	} else {
+		st = &typingState{client: c}
+		st.timer = time.AfterFunc(typingTimeout, func() { t.expire(hub, key, st) })
+		t.active[key] = st
+	}
+	st.lastSent = now
+	t.mu.Unlock()
+
+	broadcastTyping(hub, EventTypingStart, key, now)
+	return nil
+}
+
+// expire гасит индикатор по таймауту, если его не успели продлить или снять
+func (t *typingTracker) expire(hub *Hub, key typingKey, st *typingState) {
+	t.mu.Lock()
+	if t.active[key] != st {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.active, key)
+	t.mu.Unlock()
+
+	broadcastTyping(hub, EventTypingStop, key, time.Now())
+}
+
+// stopTyping молча снимает индикатор: после MESSAGE_CREATE клиенты гасят его сами
+func (t *typingTracker) stopTyping(channelID, userID string) {
+	key := typingKey{channelID: channelID, userID: userID}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if st, ok := t.active[key]; ok {
+		st.timer.Stop()
+		delete(t.active, key)
+	}
+}
+
+// dropClient снимает индикаторы закрывшегося соединения и рассылает TYPING_STOP
+func (t *typingTracker) dropClient(hub *Hub, c *Client) {
+	var stopped []typingKey
+	t.mu.Lock()
+	for key, st := range t.active {
+		if st.client == c {
+			st.timer.Stop()
+			delete(t.active, key)
+			stopped = append(stopped, key)
+		}
+	}
+	t.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range stopped {
+		broadcastTyping(hub, EventTypingStop, key, now)
+	}
+}
+
+func broadcastTyping(hub *Hub, evType string, key typingKey, at time.Time) {
+	out, _ := json.Marshal(TypingEvent{
+		Type:      evType,
+		ChannelID: key.channelID,
+		UserID:    key.userID,
+		Timestamp: at,
+	})
+	hub.Broadcast(key.channelID, out)
+}
@@ -2,45 +2,82 @@ package ws
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"math/rand"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gocql/gocql"
 	"github.com/gorilla/websocket"
 
-	_ "github.com/yourorg/chat-service/config"
-	"github.com/yourorg/chat-service/models"
-	"github.com/yourorg/chat-service/repository"
+	"github.com/yourorg/chat-service/config"
+	"github.com/yourorg/chat-service/guilds"
 )
 
 var upgrader = websocket.Upgrader{
     CheckOrigin: func(r *http.Request) bool { return true },
 }
 
+// maxSubscriptions — сколько каналов одно соединение может слушать одновременно
+const maxSubscriptions = 200
+
+var (
+    ErrNotSubscribed        = errors.New("not subscribed to channel")
+    ErrTooManySubscriptions = errors.New("too many subscriptions")
+)
+
+// Client — одно WS-соединение. Каналы, которые оно слушает, хранит Hub.
 type Client struct {
     Hub       *Hub
     Conn      *websocket.Conn
-    ChannelID string
+    ChannelID string // канал из ?channelId=, подставляется в кадры без channelId
     UserID    string
-    Send      chan []byte
+    Token     string
+
+    queue     *sendQueue    // исходящие кадры, разбирает writePump
+    done      chan struct{} // закрыт — соединение пора закрывать
+    closeMsg  []byte        // кадр закрытия для клиента; nil — просто закрыть
+    closeOnce sync.Once
 }
 
 type WSMessage struct {
     Type      string `json:"type"`
     ChannelID string `json:"channelId"`
+    ChannelIDs []string `json:"channelIds,omitempty"` // для SUBSCRIBE / UNSUBSCRIBE
+    MessageID string `json:"messageId,omitempty"`
     Content   string `json:"content"`
+    ReplyTo   string `json:"replyTo,omitempty"`
+    Attachments []string `json:"attachments,omitempty"` // ID загруженных файлов
+    Nonce     string `json:"nonce,omitempty"` // ключ идемпотентности MESSAGE_CREATE, возвращается в рассылке
 }
 
 func ServeWS(hub *Hub) gin.HandlerFunc {
     return func(c *gin.Context) {
-        // JWTAuth уже положил userId
+        // JWTAuth уже положил userId.
+        // channelId необязателен: каналы можно добавить кадрами SUBSCRIBE.
         userID := c.GetString("userId")
         channelID := c.Query("channelId")
-        if channelID == "" {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "channelId required"})
-            return
+        if channelID != "" {
+            cid, err := gocql.ParseUUID(channelID)
+            if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channelId"})
+                return
+            }
+            actor := Actor{UserID: userID, Token: c.GetString("token")}
+            if err := authorizeFresh(actor, cid); err != nil {
+                status := http.StatusInternalServerError
+                switch {
+                case errors.Is(err, ErrForbidden), errors.Is(err, guilds.ErrForbidden):
+                    status = http.StatusForbidden
+                case errors.Is(err, guilds.ErrNotFound):
+                    status = http.StatusNotFound
+                }
+                c.JSON(status, gin.H{"error": err.Error()})
+                return
+            }
         }
 
         conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -53,9 +90,14 @@ func ServeWS(hub *Hub) gin.HandlerFunc {
             Conn:      conn,
             ChannelID: channelID,
             UserID:    userID,
-            Send:      make(chan []byte),
+            Token:     c.GetString("token"),
+            queue:     hub.newQueue(),
+            done:      make(chan struct{}),
+        }
+        hub.Connect(client)
+        if channelID != "" {
+            hub.Register(channelID, client)
         }
-        hub.Register(channelID, client)
 
         // write pump
         go client.writePump()
@@ -66,9 +108,18 @@ func ServeWS(hub *Hub) gin.HandlerFunc {
 
 func (c *Client) readPump() {
     defer func() {
-        c.Hub.Unregister(c.ChannelID, c)
+        c.Hub.Disconnect(c)
+        c.Hub.typing.dropClient(c.Hub, c)
+        c.close()
         c.Conn.Close()
     }()
+
+    // Клиент обязан отвечать на ping: без pong за pongWait соединение считается мёртвым
+    c.Conn.SetReadLimit(config.WSMaxFrameBytes)
+    c.Conn.SetReadDeadline(time.Now().Add(config.WSPongWait))
+    c.Conn.SetPongHandler(func(string) error {
+        return c.Conn.SetReadDeadline(time.Now().Add(config.WSPongWait))
+    })
     for {
         _, msgBytes, err := c.Conn.ReadMessage()
         if err != nil {
@@ -80,39 +131,208 @@ func (c *Client) readPump() {
             log.Println("invalid ws message:", err)
             continue
         }
-        if in.Type != "MESSAGE_CREATE" {
+        if in.ChannelID == "" {
+            in.ChannelID = c.ChannelID
+        }
+
+        switch in.Type {
+        case EventSubscribe:
+            c.subscribe(in.channels())
+            continue
+        case EventUnsubscribe:
+            c.unsubscribe(in.channels())
             continue
         }
-		cid, err := gocql.ParseUUID(in.ChannelID)
-      		if err != nil {
-           log.Println("invalid channelID:", err)
-           continue
-       }
-        // Создаём модель и сохраняем
-       m := &models.Message{
-           ChannelID: cid,
-           MessageID: gocql.TimeUUID(), // генерация UUID Cassandra
-           SenderID:  c.UserID,
-           Content:   in.Content,
-          CreatedAt: time.Now(),
-       
-        }
-        if err := repository.SaveMessage(m); err != nil {
-            log.Println("save message:", err)
+
+        // Остальные кадры относятся к каналу и допустимы только для подписанных
+        if !c.Hub.Subscribed(c, in.ChannelID) {
+            c.fail(in, ErrNotSubscribed)
             continue
         }
-        // Шлём назад всем
-        out, _ := json.Marshal(m)
-        c.Hub.Broadcast(in.ChannelID, out)
+        // Подписка не вечна: пользователя могли убрать из гильдии
+        if err := c.authorize(in.ChannelID); err != nil {
+            c.fail(in, err)
+            continue
+        }
+        switch in.Type {
+        case EventMessageCreate:
+            m, created, err := CreateMessage(c.Hub, c.actor(), NewMessage{
+                ChannelID:   in.ChannelID,
+                Content:     in.Content,
+                ReplyTo:     in.ReplyTo,
+                Attachments: in.Attachments,
+                Nonce:       in.Nonce,
+            })
+            switch {
+            case err != nil:
+                c.fail(in, err)
+            case !created:
+                // Повтор: исходное сообщение уже разослано, напоминаем о нём только этому соединению
+                c.reply(MessageEvent{Type: EventMessageCreate, Message: m})
+            }
+        case EventTypingStart:
+            if err := StartTyping(c.Hub, c, in.ChannelID); err != nil {
+                c.fail(in, err)
+            }
+        case EventMessageUpdate:
+            if _, err := EditMessage(c.Hub, c.actor(), in.ChannelID, in.MessageID, in.Content); err != nil {
+                c.fail(in, err)
+            }
+        case EventMessageDelete:
+            if err := DeleteMessage(c.Hub, c.actor(), in.ChannelID, in.MessageID); err != nil {
+                c.fail(in, err)
+            }
+        }
     }
 }
 
-func (c *Client) writePump() {
-    for msg := range c.Send {
-        if err := c.Conn.WriteMessage(websocket.TextMessage, msg); err != nil {
-            log.Println("ws write:", err)
+// channels собирает каналы кадра SUBSCRIBE / UNSUBSCRIBE: и channelId, и channelIds
+func (in WSMessage) channels() []string {
+    ids := in.ChannelIDs
+    if in.ChannelID != "" {
+        ids = append(ids, in.ChannelID)
+    }
+    return ids
+}
+
+func (c *Client) subscribe(ids []string) {
+    added := make([]string, 0, len(ids))
+    for _, id := range ids {
+        cid, err := gocql.ParseUUID(id)
+        if err != nil {
+            c.fail(WSMessage{Type: EventSubscribe, ChannelID: id}, ErrInvalidID)
+            continue
+        }
+        if !c.Hub.Subscribed(c, id) && c.Hub.Subscriptions(c) >= maxSubscriptions {
+            c.fail(WSMessage{Type: EventSubscribe, ChannelID: id}, ErrTooManySubscriptions)
             break
         }
+        if err := authorizeFresh(c.actor(), cid); err != nil {
+            c.fail(WSMessage{Type: EventSubscribe, ChannelID: id}, err)
+            continue
+        }
+        c.Hub.Register(id, c)
+        added = append(added, id)
+    }
+    c.reply(SubscriptionEvent{Type: EventSubscribed, ChannelIDs: added})
+}
+
+func (c *Client) unsubscribe(ids []string) {
+    for _, id := range ids {
+        c.Hub.Unregister(id, c)
+    }
+    c.reply(SubscriptionEvent{Type: EventUnsubscribed, ChannelIDs: ids})
+}
+
+// authorize проверяет доступ к каналу подписки; без доступа подписка снимается
+func (c *Client) authorize(channelID string) error {
+    cid, err := gocql.ParseUUID(channelID)
+    if err != nil {
+        return ErrInvalidID
+    }
+    err = authorize(c.actor(), cid)
+    if errors.Is(err, ErrForbidden) {
+        c.Hub.Unregister(channelID, c)
+        c.reply(SubscriptionEvent{Type: EventUnsubscribed, ChannelIDs: []string{channelID}})
+    }
+    return err
+}
+
+// revalidate перепроверяет доступ ко всем подпискам соединения,
+// чтобы исключённый из гильдии перестал получать её события
+func (c *Client) revalidate() {
+    for _, channelID := range c.Hub.SubscribedChannels(c) {
+        if err := c.authorize(channelID); err != nil && !errors.Is(err, ErrForbidden) {
+            log.Println("ws revalidate:", err)
+        }
+    }
+}
+
+// fail логирует ошибку кадра и сообщает о ней клиенту.
+// Отказ по лимиту приходит отдельным кадром RATE_LIMITED.
+func (c *Client) fail(in WSMessage, err error) {
+    var limited *RateLimitError
+    if errors.As(err, &limited) {
+        c.reply(RateLimitedEvent{
+            Type:       EventRateLimited,
+            Op:         in.Type,
+            ChannelID:  in.ChannelID,
+            Nonce:      in.Nonce,
+            Scope:      limited.Scope,
+            RetryAfter: limited.RetryAfter.Seconds(),
+        })
+        return
+    }
+    log.Printf("ws %s: %v", in.Type, err)
+    c.reply(ErrorEvent{Type: EventError, Op: in.Type, ChannelID: in.ChannelID, Nonce: in.Nonce, Error: err.Error()})
+}
+
+func (c *Client) reply(v interface{}) {
+    out, _ := json.Marshal(v)
+    c.send(frame{data: out})
+}
+
+// send кладёт кадр в очередь клиента, не блокируясь.
+// Если по политике отставший клиент отключается — закрываем соединение.
+func (c *Client) send(f frame) {
+    if !c.queue.push(f) {
+        slowDisconnects.Add(1)
+        log.Printf("ws: user %s is too slow, disconnecting", c.UserID)
+        c.closeWith(websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too slow"))
+    }
+}
+
+func (c *Client) close() {
+    c.closeWith(nil)
+}
+
+// closeWith закрывает соединение; msg writePump отправит клиенту перед закрытием
+func (c *Client) closeWith(msg []byte) {
+    c.closeOnce.Do(func() {
+        c.closeMsg = msg
+        close(c.done)
+    })
+}
+
+func (c *Client) actor() Actor {
+    return Actor{UserID: c.UserID, Token: c.Token}
+}
+
+func (c *Client) writePump() {
+    ticker := time.NewTicker(config.WSPongWait * 9 / 10)
+    // Изменения прав приходят сбросом из guild-service (Hub.ForgetUsers); редкая перепроверка
+    // только подстраховывает. Первая — в случайный момент, чтобы соединения не шли разом.
+    revalidate := time.NewTimer(time.Duration(rand.Int63n(int64(config.WSRevalidateInterval))) + time.Second)
+    defer func() {
+        ticker.Stop()
+        revalidate.Stop()
+        c.Conn.Close()
+    }()
+    for {
+        select {
+        case <-c.queue.ready:
+            for _, f := range c.queue.drain() {
+                c.Conn.SetWriteDeadline(time.Now().Add(config.WSWriteWait))
+                if err := c.Conn.WriteMessage(websocket.TextMessage, f.data); err != nil {
+                    log.Println("ws write:", err)
+                    return
+                }
+                framesSent.Add(1)
+            }
+        case <-revalidate.C:
+            go c.revalidate()
+            revalidate.Reset(config.WSRevalidateInterval)
+        case <-ticker.C:
+            c.Conn.SetWriteDeadline(time.Now().Add(config.WSWriteWait))
+            if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+                log.Println("ws ping:", err)
+                return
+            }
+        case <-c.done:
+            if c.closeMsg != nil {
+                c.Conn.WriteControl(websocket.CloseMessage, c.closeMsg, time.Now().Add(config.WSWriteWait))
+            }
+            return
+        }
     }
-    c.Conn.Close()
 }
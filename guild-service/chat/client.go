@@ -0,0 +1,100 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"guild-service/config"
+)
+
+// Клиент внутренних маршрутов chat-service. Права guild-service проверяет сам,
+// а chat-service доверяет вызову по общему SERVICE_TOKEN.
+
+var httpClient = &http.Client{Timeout: time.Minute}
+
+// PurgeMessages удаляет сообщения пользователя за последние seconds секунд
+// в каналах channelIDs гильдии и их ветках
+func PurgeMessages(guildID, userID uuid.UUID, channelIDs []uuid.UUID, seconds int) error {
+	body, err := json.Marshal(map[string]interface{}{"seconds": seconds, "channelIds": channelIDs})
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/internal/guilds/%s/members/%s/messages/purge",
+		url.PathEscape(guildID.String()), url.PathEscape(userID.String()))
+	return call(http.MethodPost, path, body, nil)
+}
+
+// userBatch — сколько пользователей уходит в chat-service одним запросом
+const userBatch = 1000
+
+// ForgetPermissions просит chat-service забыть закэшированные права пользователей,
+// чтобы кик, бан, тайм-аут или смена ролей и переопределений подействовали сразу
+func ForgetPermissions(userIDs []uuid.UUID) error {
+	for len(userIDs) > 0 {
+		n := min(len(userIDs), userBatch)
+		body, err := json.Marshal(map[string]interface{}{"userIds": userIDs[:n]})
+		if err != nil {
+			return err
+		}
+		if err := call(http.MethodPost, "/internal/permissions/forget", body, nil); err != nil {
+			return err
+		}
+		userIDs = userIDs[n:]
+	}
+	return nil
+}
+
+// Online возвращает тех из userIDs, кто сейчас подключён к чату хотя бы на одной реплике
+func Online(userIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	online := make(map[uuid.UUID]bool)
+	for len(userIDs) > 0 {
+		n := min(len(userIDs), userBatch)
+		body, err := json.Marshal(map[string]interface{}{"userIds": userIDs[:n]})
+		if err != nil {
+			return nil, err
+		}
+		var out struct {
+			Online []uuid.UUID `json:"online"`
+		}
+		if err := call(http.MethodPost, "/internal/presence", body, &out); err != nil {
+			return nil, err
+		}
+		for _, id := range out.Online {
+			online[id] = true
+		}
+		userIDs = userIDs[n:]
+	}
+	return online, nil
+}
+
+// call выполняет внутренний запрос; ответ, если out не nil, разбирается в out
+func call(method, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, config.ChatServiceURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Service-Token", config.ServiceToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("chat-service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("chat-service: %s %s: status %d", method, path, resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("chat-service: %s %s: %w", method, path, err)
+		}
+	}
+	return nil
+}
@@ -3,11 +3,15 @@ package config
 import (
 	"log"
 	"os"
+	"time"
 )
 
 var (
-	DBUrl     string
-	JWTSecret string
+	DBUrl          string
+	JWTSecret      string
+	ChatServiceURL string        // куда отправлять чистку сообщений при бане
+	ServiceToken   string        // общий секрет для внутренних маршрутов chat-service
+	TemporaryGrace time.Duration // сколько временный участник может не подключаться к чату после вступления
 )
 
 func Load() {
@@ -19,4 +23,17 @@ func Load() {
 	if JWTSecret == "" {
 		log.Fatal("JWT_SECRET not set")
 	}
-}
\ No newline at end of file
+	ChatServiceURL = os.Getenv("CHAT_SERVICE_URL")
+	if ChatServiceURL == "" {
+		ChatServiceURL = "http://chat-service:8080"
+	}
+	ServiceToken = os.Getenv("SERVICE_TOKEN")
+	TemporaryGrace = 5 * time.Minute
+	if v := os.Getenv("TEMPORARY_MEMBER_GRACE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("invalid TEMPORARY_MEMBER_GRACE: %v", err)
+		}
+		TemporaryGrace = d
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"guild-service/middleware"
+	"guild-service/models"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 100
+	maxAuditReason    = 512
+)
+
+// GET /guilds/:guildId/audit-logs?actionType=&userId=&since=&until=&before=&limit=
+// Записи от новых к старым. since и until — время в RFC 3339. Для следующей страницы
+// в before передаётся id последней записи: записи с одинаковым временем так не теряются.
+func GetAuditLogs(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		q := db.Where("guild_id = ?", m.Guild.ID)
+
+		if action := c.Query("actionType"); action != "" {
+			q = q.Where("action = ?", strings.ToUpper(action))
+		}
+		if actor := c.Query("userId"); actor != "" {
+			id, err := uuid.Parse(actor)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+				return
+			}
+			q = q.Where("actor_id = ?", id)
+		}
+		for param, cond := range map[string]string{"since": "created_at >= ?", "until": "created_at < ?"} {
+			v := c.Query(param)
+			if v == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param})
+				return
+			}
+			q = q.Where(cond, t)
+		}
+		if v := c.Query("before"); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before"})
+				return
+			}
+			var cursor models.AuditLogEntry
+			if err := db.Select("id", "created_at").
+				First(&cursor, "id = ? AND guild_id = ?", id, m.Guild.ID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before"})
+				} else {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				}
+				return
+			}
+			q = q.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+		}
+		limit := defaultAuditLimit
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxAuditLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+				return
+			}
+			limit = n
+		}
+
+		entries := []models.AuditLogEntry{}
+		if err := q.Order("created_at DESC, id DESC").Limit(limit).Find(&entries).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+// recordAudit записывает действие текущего пользователя в журнал гильдии.
+// Вызывается в той же транзакции, что и само действие. targetID == uuid.Nil — цели нет.
+func recordAudit(tx *gorm.DB, c *gin.Context, guildID uuid.UUID, action models.AuditAction, targetID uuid.UUID, changes []models.AuditChange) error {
+	e := newAuditEntry(c, guildID, action, targetID, changes)
+	return tx.Create(&e).Error
+}
+
+// newAuditEntry собирает запись журнала от имени текущего пользователя
+func newAuditEntry(c *gin.Context, guildID uuid.UUID, action models.AuditAction, targetID uuid.UUID, changes []models.AuditChange) models.AuditLogEntry {
+	actorID, _ := uuid.Parse(c.GetString("userId"))
+	e := models.AuditLogEntry{
+		GuildID: guildID,
+		ActorID: actorID,
+		Action:  action,
+		Changes: changes,
+		Reason:  auditReason(c),
+	}
+	if targetID != uuid.Nil {
+		e.TargetID = &targetID
+	}
+	return e
+}
+
+// channelAuditInput — действие с каналом, которое прислал channel-service.
+// before нет у создания, after — у удаления; reason — заголовок X-Audit-Log-Reason как есть.
+type channelAuditInput struct {
+	UserID     uuid.UUID          `json:"userId" binding:"required"`
+	ActionType models.AuditAction `json:"actionType" binding:"required,oneof=CHANNEL_CREATE CHANNEL_UPDATE CHANNEL_DELETE"`
+	Before     *models.Channel    `json:"before"`
+	After      *models.Channel    `json:"after"`
+	Reason     string             `json:"reason"`
+}
+
+// POST /internal/audit-logs/channels
+// Каналы создаёт и меняет channel-service, а журнал ведётся только здесь:
+// он присылает снимки канала до и после действия, изменения считаются тут.
+func RecordChannelAudit(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in channelAuditInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ch := in.After
+		if ch == nil {
+			ch = in.Before
+		}
+		if ch == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before or after is required"})
+			return
+		}
+
+		var before, after map[string]interface{}
+		if in.Before != nil {
+			before = in.Before.AuditFields()
+		}
+		if in.After != nil {
+			after = in.After.AuditFields()
+		}
+		changes := models.AuditDiff(before, after)
+		if len(changes) == 0 {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		targetID := ch.ID
+		e := models.AuditLogEntry{
+			GuildID:  ch.GuildID,
+			ActorID:  in.UserID,
+			Action:   in.ActionType,
+			TargetID: &targetID,
+			Changes:  changes,
+			Reason:   cleanAuditReason(in.Reason),
+		}
+		if err := db.Create(&e).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, e)
+	}
+}
+
+// auditReason — причина из заголовка X-Audit-Log-Reason (URL-encoded, как в Discord)
+func auditReason(c *gin.Context) string {
+	return cleanAuditReason(c.GetHeader("X-Audit-Log-Reason"))
+}
+
+func cleanAuditReason(raw string) string {
+	if s, err := url.PathUnescape(raw); err == nil {
+		raw = s
+	}
+	reason := []rune(strings.TrimSpace(raw))
+	if len(reason) > maxAuditReason {
+		reason = reason[:maxAuditReason]
+	}
+	return string(reason)
+}
@@ -3,13 +3,16 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
+	"guild-service/middleware"
 	"guild-service/models"
+	"guild-service/permissions"
 )
 
 type createGuildInput struct {
@@ -55,7 +58,14 @@ func CreateGuild(db *gorm.DB) gin.HandlerFunc {
             Name:    in.Name,
             OwnerID: ownerID,
         }
-        if err := db.Create(&g).Error; err != nil {
+        err := db.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Create(&g).Error; err != nil {
+                return err
+            }
+            everyone := models.EveryoneRole(g.ID)
+            return tx.Create(&everyone).Error
+        })
+        if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
@@ -63,10 +73,10 @@ func CreateGuild(db *gorm.DB) gin.HandlerFunc {
     }
 }
 
-// GET /guilds/:id
+// GET /guilds/:guildId
 func GetGuild(db *gorm.DB) gin.HandlerFunc {
     return func(c *gin.Context) {
-        id, err := uuid.Parse(c.Param("id"))
+        id, err := uuid.Parse(c.Param("guildId"))
         if err != nil {
             c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
             return
@@ -84,22 +94,88 @@ func GetGuild(db *gorm.DB) gin.HandlerFunc {
         c.JSON(http.StatusOK, g)
     }
 }
-// Получение каналов по guildId
-func GetChannels(db *gorm.DB) gin.HandlerFunc {
+
+type updateGuildInput struct {
+    Name           *string `json:"name"`
+    MaxUploadBytes *int64  `json:"maxUploadBytes"`
+}
+
+// PATCH /guilds/:guildId — меняет только переданные поля
+func UpdateGuild(db *gorm.DB) gin.HandlerFunc {
     return func(c *gin.Context) {
-        guildIdParam := c.Param("guildId")
-        guildID, err := uuid.Parse(guildIdParam)
+        m := middleware.CurrentMember(c)
+        var in updateGuildInput
+        if err := c.ShouldBindJSON(&in); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+
+        updates := map[string]interface{}{}
+        if in.Name != nil {
+            name := strings.TrimSpace(*in.Name)
+            if name == "" {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "name is empty"})
+                return
+            }
+            updates["name"] = name
+        }
+        if in.MaxUploadBytes != nil {
+            if *in.MaxUploadBytes < 0 {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "maxUploadBytes must not be negative"})
+                return
+            }
+            updates["max_upload_bytes"] = *in.MaxUploadBytes
+        }
+
+        g := *m.Guild
+        before := g.AuditFields()
+        err := db.Transaction(func(tx *gorm.DB) error {
+            if len(updates) == 0 {
+                return nil
+            }
+            if err := tx.Model(&g).Updates(updates).Error; err != nil {
+                return err
+            }
+            if err := tx.First(&g, "id = ?", g.ID).Error; err != nil {
+                return err
+            }
+            changes := models.AuditDiff(before, g.AuditFields())
+            if len(changes) == 0 {
+                return nil
+            }
+            return recordAudit(tx, c, g.ID, models.AuditGuildUpdate, g.ID, changes)
+        })
         if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid guildId"})
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
+        c.JSON(http.StatusOK, g)
+    }
+}
+// Получение каналов по guildId: только те, что участник может видеть
+func GetChannels(db *gorm.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        m := middleware.CurrentMember(c)
 
         var channels []models.Channel
-        if err := db.Where("guild_id = ?", guildID).Find(&channels).Error; err != nil {
+        if err := db.Preload("PermissionOverwrites").Where("guild_id = ?", m.Guild.ID).Find(&channels).Error; err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
-        c.JSON(http.StatusOK, channels)
+        visible := make([]models.Channel, 0, len(channels))
+        for _, ch := range channels {
+            if m.InChannel(ch.PermissionOverwrites).Has(models.PermViewChannel) {
+                visible = append(visible, ch)
+            }
+        }
+        c.JSON(http.StatusOK, visible)
+    }
+}
+// GET /channels/:channelId — канал уже загружен и проверен middleware
+func GetChannel() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        ch, _ := middleware.CurrentChannel(c)
+        c.JSON(http.StatusOK, ch)
     }
 }
 type CreateChannelInput struct {
@@ -128,7 +204,13 @@ func CreateChannel(db *gorm.DB) gin.HandlerFunc {
             Type:    input.Type,
         }
 
-        if err := db.Create(&channel).Error; err != nil {
+        err = db.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Create(&channel).Error; err != nil {
+                return err
+            }
+            return recordAudit(tx, c, guildID, models.AuditChannelCreate, channel.ID, models.AuditDiff(nil, channel.AuditFields()))
+        })
+        if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
@@ -150,11 +232,63 @@ func GetMembers(db *gorm.DB) gin.HandlerFunc {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
+        roles, err := permissions.RoleIDs(db, guildID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        for i := range members {
+            members[i].Roles = roles[members[i].UserID]
+        }
 
         c.JSON(http.StatusOK, members)
     }
 }
 
+// GET /guilds/:guildId/members/:userId
+// Владелец считается участником, даже если его нет в members
+func GetMember(db *gorm.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        guildID, err := uuid.Parse(c.Param("guildId"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid guildId"})
+            return
+        }
+        userID, err := uuid.Parse(c.Param("userId"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+            return
+        }
+
+        var m models.Member
+        err = db.First(&m, "guild_id = ? AND user_id = ?", guildID, userID).Error
+        if err == nil {
+            err = db.Model(&models.MemberRole{}).
+                Where("guild_id = ? AND user_id = ?", guildID, userID).
+                Pluck("role_id", &m.Roles).Error
+        }
+        if err == nil {
+            c.JSON(http.StatusOK, m)
+            return
+        }
+        if err != gorm.ErrRecordNotFound {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        var g models.Guild
+        if err := db.First(&g, "id = ? AND owner_id = ?", guildID, userID).Error; err != nil {
+            if err == gorm.ErrRecordNotFound {
+                c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+            } else {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            }
+            return
+        }
+        c.JSON(http.StatusOK, models.Member{GuildID: g.ID, UserID: userID, JoinedAt: g.CreatedAt})
+    }
+}
+
 func AddMember(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		guildIDParam := c.Param("guildId")
@@ -177,6 +311,13 @@ func AddMember(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
 			return
 		}
+		if banned, err := isBanned(db, gid, uid); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		} else if banned {
+			c.JSON(http.StatusForbidden, gin.H{"error": "user is banned from this guild"})
+			return
+		}
 
 		member := models.Member{
 			GuildID:  gid,
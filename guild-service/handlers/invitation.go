@@ -3,6 +3,7 @@ package handlers
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"log"
 	"net/http"
 	"time"
@@ -10,14 +11,28 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"guild-service/chat"
+	"guild-service/config"
+	"guild-service/middleware"
 	"guild-service/models"
 )
 
-type CreateInvitationResponse struct {
-	Code string `json:"code"`
+// defaultInviteMaxAge — срок приглашения, если maxAge не передан
+const defaultInviteMaxAge = 7 * 24 * 60 * 60
+
+// errInviteUnusable — приглашение истекло или исчерпано, пока его принимали
+var errInviteUnusable = errors.New("invitation expired or used up")
+
+type createInvitationInput struct {
+	MaxAge    *int `json:"maxAge" binding:"omitempty,min=0,max=604800"` // секунды, 0 — бессрочное
+	MaxUses   int  `json:"maxUses" binding:"min=0,max=100"`              // 0 — без ограничения
+	Temporary bool `json:"temporary"`
 }
 
+// POST /guilds/:guildId/invites
+// Тело необязательно: по умолчанию приглашение действует 7 дней без ограничения использований.
 func CreateInvitation(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		guildIDParam := c.Param("guildId")
@@ -27,6 +42,18 @@ func CreateInvitation(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		var in createInvitationInput
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&in); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		maxAge := defaultInviteMaxAge
+		if in.MaxAge != nil {
+			maxAge = *in.MaxAge
+		}
+
 		userIDstr, _ := c.Get("userId")
 		userID, _ := uuid.Parse(userIDstr.(string))
 
@@ -42,15 +69,76 @@ func CreateInvitation(db *gorm.DB) gin.HandlerFunc {
 			Code:        code,
 			GuildID:     guildID,
 			CreatedByID: userID,
-			ExpiresAt:   time.Now().Add(7 * 24 * time.Hour), // Срок действия 7 дней
+			MaxAge:      maxAge,
+			MaxUses:     in.MaxUses,
+			Temporary:   in.Temporary,
+		}
+		if maxAge > 0 {
+			expires := time.Now().Add(time.Duration(maxAge) * time.Second)
+			invitation.ExpiresAt = &expires
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&invitation).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, guildID, models.AuditInviteCreate, invitation.ID, models.AuditDiff(nil, invitation.AuditFields()))
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
 
-		if err := db.Create(&invitation).Error; err != nil {
+		c.JSON(http.StatusCreated, invitation)
+	}
+}
+
+// GET /guilds/:guildId/invites — приглашения гильдии вместе с теми, кто по ним вступил
+func GetInvitations(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		invites := []models.Invitation{}
+		if err := db.Preload("Joins", func(q *gorm.DB) *gorm.DB { return q.Order("joined_at") }).
+			Where("guild_id = ?", m.Guild.ID).
+			Order("created_at DESC").
+			Find(&invites).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		c.JSON(http.StatusOK, invites)
+	}
+}
+
+// DELETE /guilds/:guildId/invites/:code — отозвать приглашение.
+// Можно со своим приглашением или с MANAGE_GUILD.
+func DeleteInvitation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		var inv models.Invitation
+		if err := db.First(&inv, "code = ? AND guild_id = ?", c.Param("code"), m.Guild.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "invitation not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+		if inv.CreatedByID != m.UserID && !m.Has(models.PermManageGuild) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "missing permissions"})
+			return
+		}
 
-		c.JSON(http.StatusCreated, CreateInvitationResponse{Code: code})
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&inv).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, m.Guild.ID, models.AuditInviteDelete, inv.ID, models.AuditDiff(inv.AuditFields(), nil))
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
 	}
 }
 
@@ -62,7 +150,7 @@ func GetInvite(db *gorm.DB) gin.HandlerFunc {
     var inv models.Invitation
     result := db.Where("code = ?", code).Preload("Guild").First(&inv)
     
-    if result.Error != nil {
+    if result.Error != nil || !inv.Usable() {
       log.Printf("Invitation not found: %v", result.Error)
       c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
       return
@@ -90,7 +178,6 @@ func AcceptInvitation(db *gorm.DB) gin.HandlerFunc {
             return
         }
 
-        // Остальной код без изменений...
         var invitation models.Invitation
         if err := db.Where("code = ?", code).First(&invitation).Error; err != nil {
             if err == gorm.ErrRecordNotFound {
@@ -101,33 +188,122 @@ func AcceptInvitation(db *gorm.DB) gin.HandlerFunc {
             return
         }
 
-        // Проверка срока действия
-        if time.Now().After(invitation.ExpiresAt) {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "invitation expired"})
+        // Забаненного приглашение не возвращает
+        if banned, err := isBanned(db, invitation.GuildID, userID); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        } else if banned {
+            c.JSON(http.StatusForbidden, gin.H{"error": "you are banned from this guild"})
             return
         }
 
-        // Проверка использования
-        if invitation.Used {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "invitation already used"})
+        // Уже состоящий в гильдии использование не тратит
+        var existing int64
+        if err := db.Model(&models.Member{}).
+            Where("guild_id = ? AND user_id = ?", invitation.GuildID, userID).
+            Count(&existing).Error; err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
-
-        // Добавление пользователя на сервер
-        member := models.Member{
-            GuildID:  invitation.GuildID,
-            UserID:   userID,
-            JoinedAt: time.Now(),
+        if existing > 0 {
+            c.JSON(http.StatusOK, gin.H{"status": "success", "guildId": invitation.GuildID})
+            return
         }
 
-        if err := db.Create(&member).Error; err != nil {
+        now := time.Now()
+        err = db.Transaction(func(tx *gorm.DB) error {
+            // Счётчик увеличивается одним UPDATE с проверкой лимитов: два одновременных
+            // вступления не потратят последнее использование дважды
+            res := tx.Model(&models.Invitation{}).
+                Where("id = ? AND (max_uses = 0 OR uses < max_uses) AND (expires_at IS NULL OR expires_at > ?)", invitation.ID, now).
+                Update("uses", gorm.Expr("uses + 1"))
+            if res.Error != nil {
+                return res.Error
+            }
+            if res.RowsAffected == 0 {
+                return errInviteUnusable
+            }
+
+            // Добавление пользователя на сервер
+            member := models.Member{
+                GuildID:   invitation.GuildID,
+                UserID:    userID,
+                JoinedAt:  now,
+                Temporary: invitation.Temporary,
+            }
+            if err := tx.Create(&member).Error; err != nil {
+                return err
+            }
+            // Повторный вход по тому же приглашению после выхода лишь обновляет время вступления
+            join := models.InvitationJoin{InvitationID: invitation.ID, UserID: userID, JoinedAt: now}
+            return tx.Clauses(clause.OnConflict{
+                Columns:   []clause.Column{{Name: "invitation_id"}, {Name: "user_id"}},
+                DoUpdates: clause.AssignmentColumns([]string{"joined_at"}),
+            }).Create(&join).Error
+        })
+        if errors.Is(err, errInviteUnusable) {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add member"})
             return
         }
 
-        // Помечаем приглашение как использованное
-        db.Model(&invitation).Update("used", true)
-
         c.JSON(http.StatusOK, gin.H{"status": "success", "guildId": invitation.GuildID})
     }
-}
\ No newline at end of file
+}
+
+// sweepBatch — сколько временных участников проверяется за один проход
+const sweepBatch = 5000
+
+// RunTemporarySweep периодически убирает из гильдий временных участников без ролей,
+// которые не подключены к чату ни на одной реплике chat-service. Только что вступившим
+// даётся TEMPORARY_MEMBER_GRACE, чтобы успеть подключиться.
+func RunTemporarySweep(db *gorm.DB, every time.Duration) {
+    ticker := time.NewTicker(every)
+    defer ticker.Stop()
+    for range ticker.C {
+        if err := sweepTemporaryMembers(db, time.Now().Add(-config.TemporaryGrace)); err != nil {
+            log.Println("temporary members sweep:", err)
+        }
+    }
+}
+
+func sweepTemporaryMembers(db *gorm.DB, cutoff time.Time) error {
+    var userIDs []uuid.UUID
+    if err := temporaryWithoutRoles(db, cutoff).Distinct("user_id").Limit(sweepBatch).
+        Pluck("user_id", &userIDs).Error; err != nil {
+        return err
+    }
+    if len(userIDs) == 0 {
+        return nil
+    }
+    online, err := chat.Online(userIDs)
+    if err != nil {
+        return err
+    }
+    offline := make([]uuid.UUID, 0, len(userIDs))
+    for _, id := range userIDs {
+        if !online[id] {
+            offline = append(offline, id)
+        }
+    }
+    if len(offline) == 0 {
+        return nil
+    }
+    // Условия проверяются заново: роль могли выдать, пока спрашивали chat-service
+    if err := temporaryWithoutRoles(db, cutoff).Where("user_id IN ?", offline).
+        Delete(&models.Member{}).Error; err != nil {
+        return err
+    }
+    forgetPermissions(offline...)
+    return nil
+}
+
+// temporaryWithoutRoles — временные участники, вступившие до cutoff и так и не получившие роль
+func temporaryWithoutRoles(db *gorm.DB, cutoff time.Time) *gorm.DB {
+    return db.Model(&models.Member{}).
+        Where("temporary AND joined_at < ?", cutoff).
+        Where("NOT EXISTS (SELECT 1 FROM member_roles mr WHERE mr.guild_id = members.guild_id AND mr.user_id = members.user_id)")
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"guild-service/chat"
+	"guild-service/middleware"
+	"guild-service/models"
+	"guild-service/permissions"
+)
+
+const (
+	maxBanReason       = 512
+	maxTimeoutDuration = 28 * 24 * time.Hour
+)
+
+type banInput struct {
+	Reason string `json:"reason"`
+	// Сообщения забаненного чистятся не дальше чем за неделю
+	DeleteMessageSeconds int `json:"deleteMessageSeconds" binding:"min=0,max=604800"`
+}
+
+type timeoutInput struct {
+	TimeoutUntil *time.Time `json:"timeoutUntil"` // null — снять тайм-аут
+}
+
+// DELETE /guilds/:guildId/members/:userId — выгнать участника.
+// Вернуться он сможет по новому приглашению.
+func KickMember(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		target, ok := moderationTarget(c, db, m, false)
+		if !ok {
+			return
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := removeMember(tx, m.Guild.ID, target.UserID); err != nil {
+				return err
+			}
+			return recordAudit(tx, c, m.Guild.ID, models.AuditMemberKick, target.UserID, nil)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		go forgetPermissions(target.UserID)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GET /guilds/:guildId/bans
+func GetBans(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		var bans []models.Ban
+		if err := db.Where("guild_id = ?", m.Guild.ID).Order("created_at DESC").Find(&bans).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, bans)
+	}
+}
+
+// PUT /guilds/:guildId/bans/:userId — забанить пользователя, даже если он не участник.
+// deleteMessageSeconds > 0 дополнительно удаляет его сообщения за это время через chat-service.
+func BanMember(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		var in banInput
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&in); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		in.Reason = strings.TrimSpace(in.Reason)
+		if len([]rune(in.Reason)) > maxBanReason {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reason is too long"})
+			return
+		}
+		target, ok := moderationTarget(c, db, m, true)
+		if !ok {
+			return
+		}
+
+		ban := models.Ban{
+			GuildID:    m.Guild.ID,
+			UserID:     target.UserID,
+			Reason:     in.Reason,
+			BannedByID: m.UserID,
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&ban).Error; err != nil {
+				return err
+			}
+			if err := removeMember(tx, m.Guild.ID, target.UserID); err != nil {
+				return err
+			}
+			// Причина бана из тела важнее заголовка
+			e := newAuditEntry(c, m.Guild.ID, models.AuditMemberBanAdd, target.UserID, nil)
+			if in.Reason != "" {
+				e.Reason = in.Reason
+			}
+			return tx.Create(&e).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		go forgetPermissions(ban.UserID)
+		if in.DeleteMessageSeconds > 0 {
+			go purgeMessages(db, ban.GuildID, ban.UserID, in.DeleteMessageSeconds)
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// DELETE /guilds/:guildId/bans/:userId
+func UnbanMember(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		userID, err := uuid.Parse(c.Param("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+			return
+		}
+		var found bool
+		err = db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Where("guild_id = ? AND user_id = ?", m.Guild.ID, userID).Delete(&models.Ban{})
+			if found = res.RowsAffected > 0; res.Error != nil || !found {
+				return res.Error
+			}
+			return recordAudit(tx, c, m.Guild.ID, models.AuditMemberBanRemove, userID, nil)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// PATCH /guilds/:guildId/members/:userId — поставить или снять тайм-аут.
+// В тайм-ауте участник не может писать сообщения и заходить в голосовые каналы;
+// администраторов он не касается, поэтому их в тайм-аут не отправить.
+func TimeoutMember(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		var in timeoutInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if in.TimeoutUntil != nil {
+			d := time.Until(*in.TimeoutUntil)
+			if d <= 0 || d > maxTimeoutDuration {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "timeoutUntil must be in the next 28 days"})
+				return
+			}
+		}
+		target, ok := moderationTarget(c, db, m, false)
+		if !ok {
+			return
+		}
+		if in.TimeoutUntil != nil && target.Permissions&models.PermAdministrator != 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot time out an administrator"})
+			return
+		}
+
+		var member models.Member
+		if err := db.First(&member, "guild_id = ? AND user_id = ?", m.Guild.ID, target.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+		changes := models.AuditDiff(
+			map[string]interface{}{"timeoutUntil": member.TimeoutUntil},
+			map[string]interface{}{"timeoutUntil": in.TimeoutUntil},
+		)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&member).Update("timeout_until", in.TimeoutUntil).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, m.Guild.ID, models.AuditMemberUpdate, target.UserID, changes)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		member.TimeoutUntil = in.TimeoutUntil
+		if err := db.Model(&models.MemberRole{}).
+			Where("guild_id = ? AND user_id = ?", m.Guild.ID, target.UserID).
+			Pluck("role_id", &member.Roles).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		go forgetPermissions(target.UserID)
+		c.JSON(http.StatusOK, member)
+	}
+}
+
+// purgeMessages удаляет сообщения забаненного во всех текстовых каналах гильдии:
+// BAN_MEMBERS уже проверен, права модератора в отдельных каналах не нужны
+func purgeMessages(db *gorm.DB, guildID, userID uuid.UUID, seconds int) {
+	var channelIDs []uuid.UUID
+	err := db.Model(&models.Channel{}).
+		Where("guild_id = ? AND type = ?", guildID, models.ChannelTypeText).
+		Pluck("id", &channelIDs).Error
+	if err == nil {
+		err = chat.PurgeMessages(guildID, userID, channelIDs, seconds)
+	}
+	if err != nil {
+		log.Printf("purge messages of banned user %s in guild %s: %v", userID, guildID, err)
+	}
+}
+
+// forgetPermissions сообщает chat-service, что права пользователей изменились; ошибка только логируется,
+// тогда изменение дойдёт до chat-service за GUILD_CACHE_TTL
+func forgetPermissions(userIDs ...uuid.UUID) {
+	if len(userIDs) == 0 {
+		return
+	}
+	if err := chat.ForgetPermissions(userIDs); err != nil {
+		log.Printf("forget chat permissions of %d users: %v", len(userIDs), err)
+	}
+}
+
+// forgetRoleHolders — forgetPermissions для всех, чьи права зависят от роли roleID гильдии guildID
+func forgetRoleHolders(db *gorm.DB, guildID, roleID uuid.UUID) {
+	holders, err := roleHolders(db, guildID, roleID)
+	if err != nil {
+		log.Printf("forget chat permissions of role %s: %v", roleID, err)
+		return
+	}
+	forgetPermissions(holders...)
+}
+
+// roleHolders — участники с ролью roleID; у @everyone (ID роли совпадает с ID гильдии) это вся гильдия
+func roleHolders(db *gorm.DB, guildID, roleID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if roleID == guildID {
+		err := db.Model(&models.Member{}).Where("guild_id = ?", guildID).Pluck("user_id", &ids).Error
+		return ids, err
+	}
+	err := db.Model(&models.MemberRole{}).Where("guild_id = ? AND role_id = ?", guildID, roleID).Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// moderationTarget загружает участника :userId, которого m собирается выгнать, забанить
+// или отправить в тайм-аут. Управлять можно только младшими участниками.
+// С allowOutsider цель может и не состоять в гильдии (бан заранее).
+func moderationTarget(c *gin.Context, db *gorm.DB, m *permissions.Member, allowOutsider bool) (*permissions.Member, bool) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+		return nil, false
+	}
+	target, err := permissions.Load(db, m.Guild, userID)
+	if errors.Is(err, permissions.ErrNotMember) {
+		if allowOutsider {
+			return &permissions.Member{Guild: m.Guild, UserID: userID}, true
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if !m.OutranksMember(target) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot moderate a member with an equal or higher role"})
+		return nil, false
+	}
+	return target, true
+}
+
+// removeMember удаляет участника из гильдии вместе с его ролями
+func removeMember(tx *gorm.DB, guildID, userID uuid.UUID) error {
+	if err := tx.Where("guild_id = ? AND user_id = ?", guildID, userID).Delete(&models.MemberRole{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("guild_id = ? AND user_id = ?", guildID, userID).Delete(&models.Member{}).Error
+}
+
+// isBanned сообщает, забанен ли пользователь в гильдии
+func isBanned(db *gorm.DB, guildID, userID uuid.UUID) (bool, error) {
+	var n int64
+	err := db.Model(&models.Ban{}).Where("guild_id = ? AND user_id = ?", guildID, userID).Count(&n).Error
+	return n > 0, err
+}
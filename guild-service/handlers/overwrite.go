@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"guild-service/middleware"
+	"guild-service/models"
+	"guild-service/permissions"
+)
+
+type overwriteInput struct {
+	Type  string            `json:"type" binding:"required,oneof=role member"`
+	Allow models.Permission `json:"allow"`
+	Deny  models.Permission `json:"deny"`
+}
+
+// GET /channels/:channelId/permissions — права текущего пользователя в канале
+// с учётом переопределений; 0 — канал ему не виден
+func GetMyChannelPermissions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, perms := middleware.CurrentChannel(c)
+		c.JSON(http.StatusOK, gin.H{"permissions": perms})
+	}
+}
+
+// channelMember — участник, который видит канал, и его права в канале
+type channelMember struct {
+	UserID      uuid.UUID         `json:"userId"`
+	Roles       []uuid.UUID       `json:"roles"`
+	Permissions models.Permission `json:"permissions"`
+}
+
+// GET /channels/:channelId/members — участники, которым виден канал (владелец тоже).
+// Нужен chat-service, чтобы упоминания доставлялись только тем, кто может прочитать сообщение.
+func GetChannelMembers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		ch, _ := middleware.CurrentChannel(c)
+
+		all, err := permissions.LoadAll(db, m.Guild)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		out := make([]channelMember, 0, len(all))
+		for _, mem := range all {
+			perms := mem.InChannel(ch.PermissionOverwrites)
+			if perms == 0 {
+				continue
+			}
+			roles := make([]uuid.UUID, 0, len(mem.Roles))
+			for _, r := range mem.Roles {
+				if !r.IsEveryone() {
+					roles = append(roles, r.ID)
+				}
+			}
+			out = append(out, channelMember{UserID: mem.UserID, Roles: roles, Permissions: perms})
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// PUT /channels/:channelId/permissions/:targetId
+// Разрешать и запрещать можно только те права, что есть у самого участника в этом канале.
+func PutOverwrite(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		ch, perms := middleware.CurrentChannel(c)
+
+		targetID, err := uuid.Parse(c.Param("targetId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid targetId"})
+			return
+		}
+		var in overwriteInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if in.Allow&in.Deny != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "allow and deny overlap"})
+			return
+		}
+		if !perms.Has(in.Allow | in.Deny) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot change permissions you do not have"})
+			return
+		}
+		if err := checkOverwriteTarget(db, m, in.Type, targetID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, permissions.ErrNotMember) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "target not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		o := models.ChannelOverwrite{
+			ChannelID: ch.ID,
+			TargetID:  targetID,
+			Type:      in.Type,
+			Allow:     in.Allow,
+			Deny:      in.Deny,
+		}
+		action, before := models.AuditChannelOverwriteCreate, map[string]interface{}(nil)
+		if prev := findOverwrite(ch, targetID); prev != nil {
+			action, before = models.AuditChannelOverwriteUpdate, prev.AuditFields()
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&o).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, m.Guild.ID, action, ch.ID, models.AuditDiff(before, o.AuditFields()))
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		go forgetOverwriteTarget(db, ch.GuildID, &o)
+		c.JSON(http.StatusOK, o)
+	}
+}
+
+// DELETE /channels/:channelId/permissions/:targetId
+func DeleteOverwrite(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ch, _ := middleware.CurrentChannel(c)
+		targetID, err := uuid.Parse(c.Param("targetId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid targetId"})
+			return
+		}
+		prev := findOverwrite(ch, targetID)
+		if prev == nil {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("channel_id = ? AND target_id = ?", ch.ID, targetID).
+				Delete(&models.ChannelOverwrite{}).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, ch.GuildID, models.AuditChannelOverwriteDelete, ch.ID, models.AuditDiff(prev.AuditFields(), nil))
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		go forgetOverwriteTarget(db, ch.GuildID, prev)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// checkOverwriteTarget проверяет, что роль или участник принадлежат гильдии канала
+func checkOverwriteTarget(db *gorm.DB, m *permissions.Member, typ string, targetID uuid.UUID) error {
+	if typ == models.OverwriteMember {
+		_, err := permissions.Load(db, m.Guild, targetID)
+		return err
+	}
+	if targetID == m.Guild.ID {
+		return nil // @everyone
+	}
+	var role models.Role
+	return db.First(&role, "id = ? AND guild_id = ?", targetID, m.Guild.ID).Error
+}
+
+// forgetOverwriteTarget сбрасывает в chat-service права тех, кого касается переопределение o
+func forgetOverwriteTarget(db *gorm.DB, guildID uuid.UUID, o *models.ChannelOverwrite) {
+	if o.Type == models.OverwriteMember {
+		forgetPermissions(o.TargetID)
+		return
+	}
+	forgetRoleHolders(db, guildID, o.TargetID)
+}
+
+// findOverwrite ищет переопределение цели среди загруженных с каналом
+func findOverwrite(ch *models.Channel, targetID uuid.UUID) *models.ChannelOverwrite {
+	for i := range ch.PermissionOverwrites {
+		if ch.PermissionOverwrites[i].TargetID == targetID {
+			return &ch.PermissionOverwrites[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"guild-service/middleware"
+	"guild-service/models"
+	"guild-service/permissions"
+)
+
+type createRoleInput struct {
+	Name        string            `json:"name" binding:"required"`
+	Color       int               `json:"color"`
+	Permissions models.Permission `json:"permissions"`
+}
+
+type updateRoleInput struct {
+	Name        *string            `json:"name"`
+	Color       *int               `json:"color"`
+	Permissions *models.Permission `json:"permissions"`
+	Position    *int               `json:"position"`
+}
+
+// GET /guilds/:guildId/roles — роли от старших к младшим, @everyone последней
+func GetRoles(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		var roles []models.Role
+		if err := db.Where("guild_id = ?", m.Guild.ID).Order("position DESC").Find(&roles).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, roles)
+	}
+}
+
+// POST /guilds/:guildId/roles
+// Новая роль встаёт сразу над @everyone; выдать ей можно только свои права.
+func CreateRole(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		var in createRoleInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		name := strings.TrimSpace(in.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name is empty"})
+			return
+		}
+		if !canGrant(m, in.Permissions) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot grant permissions you do not have"})
+			return
+		}
+
+		role := models.Role{
+			GuildID:     m.Guild.ID,
+			Name:        name,
+			Color:       in.Color,
+			Position:    1,
+			Permissions: in.Permissions,
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.Role{}).
+				Where("guild_id = ? AND position >= 1", m.Guild.ID).
+				Update("position", gorm.Expr("position + 1")).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&role).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, m.Guild.ID, models.AuditRoleCreate, role.ID, models.AuditDiff(nil, role.AuditFields()))
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, role)
+	}
+}
+
+// PATCH /guilds/:guildId/roles/:roleId
+// Менять можно только роли младше своей старшей; у @everyone меняются лишь права.
+func UpdateRole(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		role, ok := manageableRole(c, db, m)
+		if !ok {
+			return
+		}
+		var in updateRoleInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if role.IsEveryone() && (in.Name != nil || in.Position != nil) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "@everyone can only change permissions"})
+			return
+		}
+
+		updates := map[string]interface{}{}
+		if in.Name != nil {
+			name := strings.TrimSpace(*in.Name)
+			if name == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "name is empty"})
+				return
+			}
+			updates["name"] = name
+		}
+		if in.Color != nil {
+			updates["color"] = *in.Color
+		}
+		if in.Permissions != nil {
+			if !canGrant(m, *in.Permissions) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "cannot grant permissions you do not have"})
+				return
+			}
+			updates["permissions"] = *in.Permissions
+		}
+		if in.Position != nil && (*in.Position < 1 || !m.Outranks(*in.Position)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot move role above your highest role"})
+			return
+		}
+
+		before := role.AuditFields()
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if in.Position != nil && *in.Position != role.Position {
+				if err := moveRole(tx, role, *in.Position); err != nil {
+					return err
+				}
+			}
+			if len(updates) > 0 {
+				if err := tx.Model(role).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.First(role, "id = ?", role.ID).Error; err != nil {
+				return err
+			}
+			changes := models.AuditDiff(before, role.AuditFields())
+			if len(changes) == 0 {
+				return nil
+			}
+			return recordAudit(tx, c, m.Guild.ID, models.AuditRoleUpdate, role.ID, changes)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if in.Permissions != nil {
+			go forgetRoleHolders(db, m.Guild.ID, role.ID)
+		}
+		c.JSON(http.StatusOK, role)
+	}
+}
+
+// DELETE /guilds/:guildId/roles/:roleId
+func DeleteRole(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		role, ok := manageableRole(c, db, m)
+		if !ok {
+			return
+		}
+		if role.IsEveryone() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot delete @everyone"})
+			return
+		}
+
+		var holders []uuid.UUID
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if holders, err = roleHolders(tx, m.Guild.ID, role.ID); err != nil {
+				return err
+			}
+			if err := tx.Where("role_id = ?", role.ID).Delete(&models.MemberRole{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(role).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Role{}).
+				Where("guild_id = ? AND position > ?", role.GuildID, role.Position).
+				Update("position", gorm.Expr("position - 1")).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, m.Guild.ID, models.AuditRoleDelete, role.ID, models.AuditDiff(role.AuditFields(), nil))
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		go forgetPermissions(holders...)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// PUT /guilds/:guildId/members/:userId/roles/:roleId
+func AddMemberRole(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		role, target, ok := memberRoleTarget(c, db, m)
+		if !ok {
+			return
+		}
+		link := models.MemberRole{GuildID: m.Guild.ID, UserID: target, RoleID: role.ID}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&link)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			return recordAudit(tx, c, m.Guild.ID, models.AuditMemberRoleUpdate, target, memberRoleChange("$add", role))
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		go forgetPermissions(target)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// DELETE /guilds/:guildId/members/:userId/roles/:roleId
+func RemoveMemberRole(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		role, target, ok := memberRoleTarget(c, db, m)
+		if !ok {
+			return
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Where("guild_id = ? AND user_id = ? AND role_id = ?", m.Guild.ID, target, role.ID).
+				Delete(&models.MemberRole{})
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			return recordAudit(tx, c, m.Guild.ID, models.AuditMemberRoleUpdate, target, memberRoleChange("$remove", role))
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		go forgetPermissions(target)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GET /guilds/:guildId/permissions — права текущего пользователя в гильдии
+func GetMyPermissions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := middleware.CurrentMember(c)
+		c.JSON(http.StatusOK, gin.H{"permissions": m.Permissions})
+	}
+}
+
+// memberRoleChange — запись журнала о выданной ($add) или снятой ($remove) роли
+func memberRoleChange(key string, role *models.Role) []models.AuditChange {
+	return []models.AuditChange{{Key: key, New: gin.H{"id": role.ID, "name": role.Name}}}
+}
+
+// canGrant — роль можно наделить только правами, которые есть у самого участника
+func canGrant(m *permissions.Member, p models.Permission) bool {
+	return m.Has(p)
+}
+
+// manageableRole находит роль :roleId гильдии и проверяет, что она младше старшей роли участника
+func manageableRole(c *gin.Context, db *gorm.DB, m *permissions.Member) (*models.Role, bool) {
+	roleID, err := uuid.Parse(c.Param("roleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid roleId"})
+		return nil, false
+	}
+	var role models.Role
+	if err := db.First(&role, "id = ? AND guild_id = ?", roleID, m.Guild.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	if !role.IsEveryone() && !m.Outranks(role.Position) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "role is above your highest role"})
+		return nil, false
+	}
+	return &role, true
+}
+
+// memberRoleTarget проверяет роль и участника :userId, которому её выдают или снимают
+func memberRoleTarget(c *gin.Context, db *gorm.DB, m *permissions.Member) (*models.Role, uuid.UUID, bool) {
+	role, ok := manageableRole(c, db, m)
+	if !ok {
+		return nil, uuid.Nil, false
+	}
+	if role.IsEveryone() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "@everyone cannot be assigned"})
+		return nil, uuid.Nil, false
+	}
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+		return nil, uuid.Nil, false
+	}
+	if _, err := permissions.Load(db, m.Guild, userID); err != nil {
+		if errors.Is(err, permissions.ErrNotMember) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, uuid.Nil, false
+	}
+	return role, userID, true
+}
+
+// moveRole переставляет роль на позицию to, сдвигая роли между старой и новой позицией
+func moveRole(tx *gorm.DB, role *models.Role, to int) error {
+	var top int
+	if err := tx.Model(&models.Role{}).Where("guild_id = ?", role.GuildID).
+		Select("COALESCE(MAX(position), 0)").Scan(&top).Error; err != nil {
+		return err
+	}
+	if to > top {
+		to = top
+	}
+	from := role.Position
+	q := tx.Model(&models.Role{}).Where("guild_id = ? AND id <> ?", role.GuildID, role.ID)
+	var err error
+	if to > from {
+		err = q.Where("position > ? AND position <= ?", from, to).
+			Update("position", gorm.Expr("position - 1")).Error
+	} else {
+		err = q.Where("position >= ? AND position < ?", to, from).
+			Update("position", gorm.Expr("position + 1")).Error
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Model(role).Update("position", to).Error
+}
@@ -11,7 +11,9 @@ import (
     "gorm.io/gorm"
 
     "guild-service/config"
+    "guild-service/handlers"
     "guild-service/models"
+    "guild-service/permissions"
     "guild-service/routes"
 )
 
@@ -48,11 +50,45 @@ func main() {
     if err := db.AutoMigrate(
         &models.Guild{},
         &models.Invitation{},
+        &models.InvitationJoin{},
         &models.Member{},
         &models.Channel{},
+        &models.Role{},
+        &models.MemberRole{},
+        &models.ChannelOverwrite{},
+        &models.Ban{},
+        &models.AuditLogEntry{},
     ); err != nil {
         log.Fatalf("migration failed: %v", err)
     }
+    // Раньше любое приглашение было одноразовым с флагом used: переносим его в счётчик использований
+    if db.Migrator().HasColumn(&models.Invitation{}, "used") {
+        if err := db.Exec(`UPDATE invitations SET max_uses = 1, uses = CASE WHEN used THEN 1 ELSE 0 END`).Error; err != nil {
+            log.Fatalf("failed to migrate invitations: %v", err)
+        }
+        if err := db.Migrator().DropColumn(&models.Invitation{}, "used"); err != nil {
+            log.Fatalf("failed to migrate invitations: %v", err)
+        }
+    }
+    // Журнал аудита только дополняется: UPDATE и DELETE запрещены на уровне базы
+    for _, stmt := range []string{
+        `CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+         BEGIN RAISE EXCEPTION 'audit_log_entries is append-only'; END;
+         $$ LANGUAGE plpgsql`,
+        `DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log_entries`,
+        `CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log_entries
+         FOR EACH ROW EXECUTE FUNCTION audit_log_append_only()`,
+    } {
+        if err := db.Exec(stmt).Error; err != nil {
+            log.Fatalf("failed to protect audit log: %v", err)
+        }
+    }
+    // У гильдий, созданных до ролей, нет @everyone
+    if err := permissions.EnsureEveryone(db); err != nil {
+        log.Fatalf("failed to create @everyone roles: %v", err)
+    }
+    // Временные участники, отключившиеся от чата, убираются из гильдий
+    go handlers.RunTemporarySweep(db, time.Minute)
     // Запускаем HTTP
     r := gin.Default()
     // Регистрируем защищённые маршруты
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"guild-service/models"
+	"guild-service/permissions"
+)
+
+// RequirePermission пускает только участников гильдии :guildId с правами perm (0 — достаточно членства).
+// Участник с правами кладётся в контекст, его достаёт CurrentMember.
+func RequirePermission(db *gorm.DB, perm models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		guildID, err := uuid.Parse(c.Param("guildId"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid guildId"})
+			return
+		}
+		m, ok := loadMember(c, db, guildID)
+		if !ok {
+			return
+		}
+		if !m.Has(perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing permissions"})
+			return
+		}
+		c.Set("member", m)
+		c.Next()
+	}
+}
+
+// RequireChannelPermission — то же для маршрутов канала :channelId, но права считаются
+// с переопределениями канала. В контекст кладутся участник, канал и права в нём.
+func RequireChannelPermission(db *gorm.DB, perm models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		channelID, err := uuid.Parse(c.Param("channelId"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid channelId"})
+			return
+		}
+		var ch models.Channel
+		if err := db.Preload("PermissionOverwrites").First(&ch, "id = ?", channelID).Error; err != nil {
+			abortLookup(c, err)
+			return
+		}
+		m, ok := loadMember(c, db, ch.GuildID)
+		if !ok {
+			return
+		}
+		perms := m.InChannel(ch.PermissionOverwrites)
+		if !perms.Has(perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing permissions"})
+			return
+		}
+		c.Set("member", m)
+		c.Set("channel", &ch)
+		c.Set("channelPermissions", perms)
+		c.Next()
+	}
+}
+
+// CurrentMember возвращает участника, проверенного RequirePermission или RequireChannelPermission
+func CurrentMember(c *gin.Context) *permissions.Member {
+	return c.MustGet("member").(*permissions.Member)
+}
+
+// CurrentChannel возвращает канал, загруженный RequireChannelPermission
+func CurrentChannel(c *gin.Context) (*models.Channel, models.Permission) {
+	return c.MustGet("channel").(*models.Channel), c.MustGet("channelPermissions").(models.Permission)
+}
+
+// loadMember загружает гильдию и права текущего пользователя в ней
+func loadMember(c *gin.Context, db *gorm.DB, guildID uuid.UUID) (*permissions.Member, bool) {
+	userID, err := uuid.Parse(c.GetString("userId"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid subject"})
+		return nil, false
+	}
+	var g models.Guild
+	if err := db.First(&g, "id = ?", guildID).Error; err != nil {
+		abortLookup(c, err)
+		return nil, false
+	}
+
+	m, err := permissions.Load(db, &g, userID)
+	if errors.Is(err, permissions.ErrNotMember) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return m, true
+}
+
+func abortLookup(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
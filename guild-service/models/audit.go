@@ -0,0 +1,113 @@
+package models
+
+import (
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditAction — тип записи журнала аудита. Названия повторяют Discord.
+type AuditAction string
+
+const (
+	AuditGuildUpdate            AuditAction = "GUILD_UPDATE"
+	AuditChannelCreate          AuditAction = "CHANNEL_CREATE"
+	AuditChannelUpdate          AuditAction = "CHANNEL_UPDATE"
+	AuditChannelDelete          AuditAction = "CHANNEL_DELETE"
+	AuditChannelOverwriteCreate AuditAction = "CHANNEL_OVERWRITE_CREATE"
+	AuditChannelOverwriteUpdate AuditAction = "CHANNEL_OVERWRITE_UPDATE"
+	AuditChannelOverwriteDelete AuditAction = "CHANNEL_OVERWRITE_DELETE"
+	AuditMemberKick             AuditAction = "MEMBER_KICK"
+	AuditMemberBanAdd           AuditAction = "MEMBER_BAN_ADD"
+	AuditMemberBanRemove        AuditAction = "MEMBER_BAN_REMOVE"
+	AuditMemberUpdate           AuditAction = "MEMBER_UPDATE"
+	AuditMemberRoleUpdate       AuditAction = "MEMBER_ROLE_UPDATE"
+	AuditRoleCreate             AuditAction = "ROLE_CREATE"
+	AuditRoleUpdate             AuditAction = "ROLE_UPDATE"
+	AuditRoleDelete             AuditAction = "ROLE_DELETE"
+	AuditInviteCreate           AuditAction = "INVITE_CREATE"
+	AuditInviteDelete           AuditAction = "INVITE_DELETE"
+)
+
+// AuditChange — изменение одного поля: old нет у создания, new — у удаления
+type AuditChange struct {
+	Key string      `json:"key"`
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// AuditLogEntry — запись журнала аудита. Таблица только дополняется:
+// изменить или удалить запись не даёт триггер в базе (см. main.go).
+type AuditLogEntry struct {
+	ID        uuid.UUID     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	GuildID   uuid.UUID     `gorm:"type:uuid;not null;index:idx_audit_guild_time,priority:1" json:"guildId"`
+	ActorID   uuid.UUID     `gorm:"type:uuid;not null;index"                             json:"userId"`
+	Action    AuditAction   `gorm:"not null;index"                                       json:"actionType"`
+	TargetID  *uuid.UUID    `gorm:"type:uuid"                                            json:"targetId"`
+	Changes   []AuditChange `gorm:"type:jsonb;serializer:json"                           json:"changes"`
+	Reason    string        `gorm:"not null;default:''"                                  json:"reason,omitempty"`
+	CreatedAt time.Time     `gorm:"autoCreateTime;index:idx_audit_guild_time,priority:2" json:"createdAt"`
+}
+
+func (e *AuditLogEntry) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return
+}
+
+// AuditDiff сравнивает снимки полей до и после действия. У создания before == nil,
+// у удаления after == nil. Ключи идут по алфавиту.
+func AuditDiff(before, after map[string]interface{}) []AuditChange {
+	keys := make([]string, 0, len(before)+len(after))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []AuditChange
+	for _, k := range keys {
+		old, hadOld := before[k]
+		cur, hasNew := after[k]
+		if hadOld && hasNew && reflect.DeepEqual(old, cur) {
+			continue
+		}
+		changes = append(changes, AuditChange{Key: k, Old: old, New: cur})
+	}
+	return changes
+}
+
+// AuditFields — поля гильдии, которые попадают в журнал
+func (g *Guild) AuditFields() map[string]interface{} {
+	return map[string]interface{}{"name": g.Name, "maxUploadBytes": g.MaxUploadBytes}
+}
+
+// AuditFields — поля канала, которые попадают в журнал
+func (c *Channel) AuditFields() map[string]interface{} {
+	return map[string]interface{}{"name": c.Name, "type": c.Type, "slowmodeSeconds": c.SlowmodeSeconds}
+}
+
+// AuditFields — поля роли, которые попадают в журнал
+func (r *Role) AuditFields() map[string]interface{} {
+	return map[string]interface{}{"name": r.Name, "color": r.Color, "position": r.Position, "permissions": r.Permissions}
+}
+
+// AuditFields — поля переопределения, которые попадают в журнал
+func (o *ChannelOverwrite) AuditFields() map[string]interface{} {
+	return map[string]interface{}{"id": o.TargetID, "type": o.Type, "allow": o.Allow, "deny": o.Deny}
+}
+
+// AuditFields — поля приглашения, которые попадают в журнал
+func (i *Invitation) AuditFields() map[string]interface{} {
+	return map[string]interface{}{
+		"code": i.Code, "maxAge": i.MaxAge, "maxUses": i.MaxUses, "uses": i.Uses, "temporary": i.Temporary,
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ban — бан пользователя в гильдии. Пока он есть, пользователя нельзя вернуть
+// ни приглашением, ни добавлением напрямую.
+type Ban struct {
+	GuildID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"guildId"`
+	UserID     uuid.UUID `gorm:"type:uuid;primaryKey" json:"userId"`
+	Reason     string    `gorm:"not null;default:''"  json:"reason"`
+	BannedByID uuid.UUID `gorm:"type:uuid;not null"   json:"bannedBy"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"       json:"createdAt"`
+}
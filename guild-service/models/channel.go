@@ -19,6 +19,10 @@ type Channel struct {
     Name      string      `gorm:"not null"`
     Type      ChannelType `gorm:"type:channel_type;not null"`
     CreatedAt time.Time   `gorm:"autoCreateTime"`
+    // Медленный режим в секундах; задаётся через channel-service, здесь только читается
+    SlowmodeSeconds int `gorm:"not null;default:0"`
+    // Переопределения прав для ролей и участников; удаляются вместе с каналом
+    PermissionOverwrites []ChannelOverwrite `gorm:"foreignKey:ChannelID;constraint:OnDelete:CASCADE"`
 }
 
 func (c *Channel) BeforeCreate(tx *gorm.DB) (err error) {
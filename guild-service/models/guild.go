@@ -13,6 +13,8 @@ type Guild struct {
   Name      string    `gorm:"not null;uniqueIndex"                  json:"name"`
   OwnerID   uuid.UUID `gorm:"type:uuid;not null;index"             json:"ownerId"`
   CreatedAt time.Time `gorm:"autoCreateTime"                       json:"createdAt"`
+  // Лимит размера вложения в байтах; 0 — лимит chat-service по умолчанию
+  MaxUploadBytes int64 `gorm:"not null;default:0"                 json:"maxUploadBytes"`
 }
 
 // BeforeCreate заполнит ID, если он пустой
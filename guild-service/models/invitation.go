@@ -7,15 +7,22 @@ import (
 	"gorm.io/gorm"
 )
 
+// Invitation — приглашение в гильдию. MaxUses и MaxAge равные 0 — без ограничений.
+// Temporary — вступивший по нему участник удаляется, когда отключается (если ему не выдали роль).
 type Invitation struct {
-	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
-	Code        string    `gorm:"uniqueIndex;not null"`
-	GuildID     uuid.UUID `gorm:"type:uuid;not null;index"`
-	CreatedByID uuid.UUID `gorm:"type:uuid;not null;index"`
-	CreatedAt   time.Time `gorm:"autoCreateTime"`
-	ExpiresAt   time.Time
-	Used        bool `gorm:"default:false"`
-	Guild   Guild `gorm:"foreignKey:GuildID"`
+	ID          uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Code        string     `gorm:"uniqueIndex;not null"          json:"code"`
+	GuildID     uuid.UUID  `gorm:"type:uuid;not null;index"      json:"guildId"`
+	CreatedByID uuid.UUID  `gorm:"type:uuid;not null;index"      json:"createdBy"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime"                json:"createdAt"`
+	ExpiresAt   *time.Time `json:"expiresAt"` // nil — бессрочное
+	MaxAge      int        `gorm:"not null;default:0"            json:"maxAge"` // в секундах
+	MaxUses     int        `gorm:"not null;default:0"            json:"maxUses"`
+	Uses        int        `gorm:"not null;default:0"            json:"uses"`
+	Temporary   bool       `gorm:"not null;default:false"        json:"temporary"`
+	Guild   Guild `gorm:"foreignKey:GuildID" json:"-"`
+	// Кто вступил по приглашению
+	Joins []InvitationJoin `gorm:"foreignKey:InvitationID;constraint:OnDelete:CASCADE" json:"joins,omitempty"`
 }
 
 func (i *Invitation) BeforeCreate(tx *gorm.DB) (err error) {
@@ -23,4 +30,19 @@ func (i *Invitation) BeforeCreate(tx *gorm.DB) (err error) {
 		i.ID = uuid.New()
 	}
 	return
+}
+
+// Usable сообщает, что приглашение не истекло и не исчерпано
+func (i *Invitation) Usable() bool {
+	if i.ExpiresAt != nil && !time.Now().Before(*i.ExpiresAt) {
+		return false
+	}
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
+
+// InvitationJoin — пользователь, вступивший по приглашению
+type InvitationJoin struct {
+	InvitationID uuid.UUID `gorm:"type:uuid;primaryKey" json:"-"`
+	UserID       uuid.UUID `gorm:"type:uuid;primaryKey" json:"userId"`
+	JoinedAt     time.Time `json:"joinedAt"`
 }
\ No newline at end of file
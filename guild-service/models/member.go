@@ -9,4 +9,15 @@ type Member struct {
 	GuildID  uuid.UUID `gorm:"type:uuid;primaryKey" json:"guildId"`
 	UserID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"userId"`
 	JoinedAt time.Time `json:"joinedAt"`
+	// Временный участник удаляется из гильдии, когда отключается, если у него нет ролей
+	Temporary bool `gorm:"not null;default:false" json:"temporary"`
+	// До этого момента участник в тайм-ауте: может только читать
+	TimeoutUntil *time.Time `json:"timeoutUntil,omitempty"`
+	// Роли участника без @everyone; хранятся в MemberRole
+	Roles []uuid.UUID `gorm:"-" json:"roles"`
+}
+
+// TimedOut сообщает, что участник сейчас в тайм-ауте
+func (m *Member) TimedOut() bool {
+	return m.TimeoutUntil != nil && time.Now().Before(*m.TimeoutUntil)
 }
\ No newline at end of file
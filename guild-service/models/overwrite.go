@@ -0,0 +1,19 @@
+package models
+
+import "github.com/google/uuid"
+
+// Типы цели переопределения прав канала
+const (
+	OverwriteRole   = "role"
+	OverwriteMember = "member"
+)
+
+// ChannelOverwrite — переопределение прав в канале для роли или участника.
+// Для @everyone цель — роль с ID гильдии.
+type ChannelOverwrite struct {
+	ChannelID uuid.UUID  `gorm:"type:uuid;primaryKey"            json:"channelId"`
+	TargetID  uuid.UUID  `gorm:"type:uuid;primaryKey"            json:"id"`
+	Type      string     `gorm:"not null"                        json:"type"`
+	Allow     Permission `gorm:"not null;default:0"              json:"allow"`
+	Deny      Permission `gorm:"not null;default:0"              json:"deny"`
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Permission — битовое поле прав. Значения битов совпадают с Discord,
+// чтобы клиентам было проще их переиспользовать.
+type Permission int64
+
+const (
+	PermCreateInvite    Permission = 1 << 0
+	PermKickMembers     Permission = 1 << 1
+	PermBanMembers      Permission = 1 << 2
+	PermAdministrator   Permission = 1 << 3 // все права и обход переопределений каналов
+	PermManageChannels  Permission = 1 << 4
+	PermManageGuild     Permission = 1 << 5
+	PermAddReactions    Permission = 1 << 6
+	PermViewAuditLog    Permission = 1 << 7
+	PermViewChannel     Permission = 1 << 10
+	PermSendMessages    Permission = 1 << 11
+	PermManageMessages  Permission = 1 << 13
+	PermAttachFiles     Permission = 1 << 15
+	PermReadHistory     Permission = 1 << 16
+	PermMentionEveryone Permission = 1 << 17
+	PermConnect         Permission = 1 << 20
+	PermSpeak           Permission = 1 << 21
+	PermManageRoles     Permission = 1 << 28
+	PermModerateMembers Permission = 1 << 40
+)
+
+// PermAll — все известные права
+const PermAll = PermCreateInvite | PermKickMembers | PermBanMembers | PermAdministrator |
+	PermManageChannels | PermManageGuild | PermAddReactions | PermViewAuditLog |
+	PermViewChannel | PermSendMessages | PermManageMessages | PermAttachFiles |
+	PermReadHistory | PermMentionEveryone | PermConnect | PermSpeak |
+	PermManageRoles | PermModerateMembers
+
+// PermDefault — права @everyone в новой гильдии
+const PermDefault = PermCreateInvite | PermAddReactions | PermViewChannel | PermSendMessages |
+	PermAttachFiles | PermReadHistory | PermConnect | PermSpeak
+
+// PermTimedOut — всё, что остаётся участнику в тайм-ауте: читать каналы и историю
+const PermTimedOut = PermViewChannel | PermReadHistory
+
+// Has сообщает, есть ли все права из want
+func (p Permission) Has(want Permission) bool {
+	if p&PermAdministrator != 0 {
+		return true
+	}
+	return p&want == want
+}
+
+// Role — роль гильдии. Роль @everyone есть в каждой гильдии, её ID совпадает с ID гильдии,
+// а позиция всегда 0. Чем выше Position, тем старше роль.
+type Role struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	GuildID     uuid.UUID  `gorm:"type:uuid;not null;index"                         json:"guildId"`
+	Name        string     `gorm:"not null"                                         json:"name"`
+	Color       int        `gorm:"not null;default:0"                               json:"color"`
+	Position    int        `gorm:"not null;default:0"                               json:"position"`
+	Permissions Permission `gorm:"not null;default:0"                               json:"permissions"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime"                                   json:"createdAt"`
+}
+
+func (r *Role) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
+
+// IsEveryone сообщает, что это роль @everyone
+func (r *Role) IsEveryone() bool {
+	return r.ID == r.GuildID
+}
+
+// EveryoneRole — роль @everyone для гильдии
+func EveryoneRole(guildID uuid.UUID) Role {
+	return Role{ID: guildID, GuildID: guildID, Name: "@everyone", Permissions: PermDefault}
+}
+
+// MemberRole — роль, выданная участнику. @everyone не выдаётся: она есть у всех.
+type MemberRole struct {
+	GuildID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID  uuid.UUID `gorm:"type:uuid;primaryKey"`
+	RoleID  uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+}
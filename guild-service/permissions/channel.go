@@ -0,0 +1,49 @@
+package permissions
+
+import "guild-service/models"
+
+// InChannel накладывает на права участника переопределения канала в порядке Discord:
+// сначала @everyone, затем все роли участника вместе, затем сам участник.
+// Без VIEW_CHANNEL канала для участника нет — остальные права тоже снимаются.
+// Тайм-аут переопределениями не обходится.
+func (m *Member) InChannel(overwrites []models.ChannelOverwrite) models.Permission {
+	if m.IsOwner || m.Permissions&models.PermAdministrator != 0 {
+		return models.PermAll
+	}
+
+	roles := make(map[string]bool, len(m.Roles))
+	for _, r := range m.Roles {
+		roles[r.ID.String()] = true
+	}
+
+	perms := m.Permissions
+	var everyone, member *models.ChannelOverwrite
+	var allow, deny models.Permission
+	for i := range overwrites {
+		o := &overwrites[i]
+		switch {
+		case o.Type == models.OverwriteRole && o.TargetID == m.Guild.ID:
+			everyone = o
+		case o.Type == models.OverwriteRole && roles[o.TargetID.String()]:
+			allow |= o.Allow
+			deny |= o.Deny
+		case o.Type == models.OverwriteMember && o.TargetID == m.UserID:
+			member = o
+		}
+	}
+	if everyone != nil {
+		perms = perms&^everyone.Deny | everyone.Allow
+	}
+	perms = perms&^deny | allow
+	if member != nil {
+		perms = perms&^member.Deny | member.Allow
+	}
+
+	if m.TimedOut {
+		perms &= models.PermTimedOut
+	}
+	if perms&models.PermViewChannel == 0 {
+		return 0
+	}
+	return perms
+}
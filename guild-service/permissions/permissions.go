@@ -0,0 +1,197 @@
+package permissions
+
+import (
+	"errors"
+	"math"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"guild-service/models"
+)
+
+// ErrNotMember — пользователь не состоит в гильдии
+var ErrNotMember = errors.New("not a member of this guild")
+
+// Member — участник гильдии с ролями и итоговыми правами на уровне гильдии
+type Member struct {
+	Guild       *models.Guild
+	UserID      uuid.UUID
+	IsOwner     bool
+	TimedOut    bool
+	Roles       []models.Role // вместе с @everyone
+	Permissions models.Permission
+}
+
+// Load собирает права участника: владелец получает всё, остальные — объединение прав
+// @everyone и своих ролей. ADMINISTRATOR равен всем правам.
+// В тайм-ауте у участника остаются только PermTimedOut; владельца и администраторов он не касается.
+func Load(db *gorm.DB, guild *models.Guild, userID uuid.UUID) (*Member, error) {
+	m := &Member{Guild: guild, UserID: userID, IsOwner: guild.OwnerID == userID}
+	if !m.IsOwner {
+		var row models.Member
+		err := db.First(&row, "guild_id = ? AND user_id = ?", guild.ID, userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotMember
+		}
+		if err != nil {
+			return nil, err
+		}
+		m.TimedOut = row.TimedOut()
+	}
+
+	everyone, err := Everyone(db, guild.ID)
+	if err != nil {
+		return nil, err
+	}
+	var roles []models.Role
+	if err := db.Where("id IN (?)", db.Model(&models.MemberRole{}).
+		Select("role_id").
+		Where("guild_id = ? AND user_id = ?", guild.ID, userID)).
+		Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	m.Roles = append([]models.Role{*everyone}, roles...)
+
+	m.resolve()
+	return m, nil
+}
+
+// LoadAll — то же, что Load, сразу для всех участников гильдии, включая владельца
+func LoadAll(db *gorm.DB, guild *models.Guild) ([]*Member, error) {
+	var rows []models.Member
+	if err := db.Where("guild_id = ?", guild.ID).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	everyone, err := Everyone(db, guild.ID)
+	if err != nil {
+		return nil, err
+	}
+	var roles []models.Role
+	if err := db.Where("guild_id = ?", guild.ID).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]models.Role, len(roles))
+	for _, r := range roles {
+		byID[r.ID] = r
+	}
+	memberRoles, err := RoleIDs(db, guild.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*Member, 0, len(rows)+1)
+	owner := &Member{Guild: guild, UserID: guild.OwnerID, IsOwner: true}
+	for _, row := range rows {
+		if row.UserID == guild.OwnerID {
+			continue
+		}
+		m := &Member{Guild: guild, UserID: row.UserID, TimedOut: row.TimedOut()}
+		out = append(out, m)
+	}
+	out = append(out, owner)
+	for _, m := range out {
+		m.Roles = []models.Role{*everyone}
+		for _, id := range memberRoles[m.UserID] {
+			if r, ok := byID[id]; ok {
+				m.Roles = append(m.Roles, r)
+			}
+		}
+		m.resolve()
+	}
+	return out, nil
+}
+
+// resolve считает права гильдии по ролям участника
+func (m *Member) resolve() {
+	if m.IsOwner {
+		m.Permissions = models.PermAll
+		return
+	}
+	for _, r := range m.Roles {
+		m.Permissions |= r.Permissions
+	}
+	if m.Permissions&models.PermAdministrator != 0 {
+		m.Permissions = models.PermAll
+		m.TimedOut = false
+	}
+	if m.TimedOut {
+		m.Permissions &= models.PermTimedOut
+	}
+}
+
+// Everyone возвращает роль @everyone гильдии; у старых гильдий её может не быть в базе
+func Everyone(db *gorm.DB, guildID uuid.UUID) (*models.Role, error) {
+	var r models.Role
+	err := db.First(&r, "id = ?", guildID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		r = models.EveryoneRole(guildID)
+		return &r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Has сообщает, есть ли у участника все права из want
+func (m *Member) Has(want models.Permission) bool {
+	return m.Permissions.Has(want)
+}
+
+// TopPosition — позиция старшей роли участника; владелец старше любой роли
+func (m *Member) TopPosition() int {
+	if m.IsOwner {
+		return math.MaxInt
+	}
+	top := 0
+	for _, r := range m.Roles {
+		if r.Position > top {
+			top = r.Position
+		}
+	}
+	return top
+}
+
+// Outranks сообщает, что участник может управлять ролью на позиции position
+func (m *Member) Outranks(position int) bool {
+	return m.TopPosition() > position
+}
+
+// OutranksMember сообщает, что участник старше other: только так можно управлять другим участником
+func (m *Member) OutranksMember(other *Member) bool {
+	if other.IsOwner {
+		return false
+	}
+	return m.TopPosition() > other.TopPosition()
+}
+
+// RoleIDs возвращает роли участников гильдии (без @everyone) по пользователям
+func RoleIDs(db *gorm.DB, guildID uuid.UUID) (map[uuid.UUID][]uuid.UUID, error) {
+	var rows []models.MemberRole
+	if err := db.Where("guild_id = ?", guildID).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uuid.UUID][]uuid.UUID)
+	for _, r := range rows {
+		out[r.UserID] = append(out[r.UserID], r.RoleID)
+	}
+	return out, nil
+}
+
+// EnsureEveryone создаёт роль @everyone гильдиям, у которых её ещё нет
+func EnsureEveryone(db *gorm.DB) error {
+	var ids []uuid.UUID
+	if err := db.Model(&models.Guild{}).
+		Where("id NOT IN (?)", db.Model(&models.Role{}).Select("id")).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		r := models.EveryoneRole(id)
+		if err := db.Create(&r).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
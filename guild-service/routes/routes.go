@@ -8,6 +8,7 @@ import (
 
 	"guild-service/handlers"
 	"guild-service/middleware"
+	"guild-service/models"
 )
 
 func Register(r gin.IRouter, db *gorm.DB) {
@@ -16,17 +17,57 @@ func Register(r gin.IRouter, db *gorm.DB) {
     // 1. Зарегистрируем специфичные маршруты перед общими
     auth.GET("/invitations/:code", handlers.GetInvite(db)) // Добавлено
     
-    // 2. Остальные маршруты
+    // 2. Остальные маршруты. Всё, что относится к конкретной гильдии,
+    // проходит через RequirePermission: member — достаточно членства
+    member := middleware.RequirePermission(db, 0)
+    can := func(p models.Permission) gin.HandlerFunc { return middleware.RequirePermission(db, p) }
+    channel := func(p models.Permission) gin.HandlerFunc { return middleware.RequireChannelPermission(db, p) }
+
     auth.GET("/guilds", handlers.GetGuilds(db))
     auth.POST("/guilds", handlers.CreateGuild(db))
-    auth.GET("/guilds/:guildId", handlers.GetGuild(db))
-    auth.GET("/guilds/:guildId/channels", handlers.GetChannels(db))
-    auth.POST("/guilds/:guildId/channels", handlers.CreateChannel(db))
-    auth.GET("/guilds/:guildId/members", handlers.GetMembers(db))
-    auth.POST("/guilds/:guildId/members", handlers.AddMember(db))
-    auth.POST("/guilds/:guildId/invites", handlers.CreateInvitation(db))
+    auth.GET("/guilds/:guildId", member, handlers.GetGuild(db))
+    auth.PATCH("/guilds/:guildId", can(models.PermManageGuild), handlers.UpdateGuild(db))
+    auth.GET("/guilds/:guildId/channels", member, handlers.GetChannels(db))
+    auth.POST("/guilds/:guildId/channels", can(models.PermManageChannels), handlers.CreateChannel(db))
+    auth.GET("/channels/:channelId", channel(models.PermViewChannel), handlers.GetChannel())
+    auth.GET("/guilds/:guildId/members", member, handlers.GetMembers(db))
+    auth.GET("/guilds/:guildId/members/:userId", member, handlers.GetMember(db))
+    // Добавить участника напрямую — то же, что пригласить
+    auth.POST("/guilds/:guildId/members", can(models.PermCreateInvite), handlers.AddMember(db))
+    auth.POST("/guilds/:guildId/invites", can(models.PermCreateInvite), handlers.CreateInvitation(db))
+    auth.GET("/guilds/:guildId/invites", can(models.PermManageGuild), handlers.GetInvitations(db))
+    auth.DELETE("/guilds/:guildId/invites/:code", member, handlers.DeleteInvitation(db))
     auth.POST("/invites/:code/accept", handlers.AcceptInvitation(db))
+
+    // Модерация: выгнать, тайм-аут, баны
+    auth.DELETE("/guilds/:guildId/members/:userId", can(models.PermKickMembers), handlers.KickMember(db))
+    auth.PATCH("/guilds/:guildId/members/:userId", can(models.PermModerateMembers), handlers.TimeoutMember(db))
+    auth.GET("/guilds/:guildId/bans", can(models.PermBanMembers), handlers.GetBans(db))
+    auth.PUT("/guilds/:guildId/bans/:userId", can(models.PermBanMembers), handlers.BanMember(db))
+    auth.DELETE("/guilds/:guildId/bans/:userId", can(models.PermBanMembers), handlers.UnbanMember(db))
+
+    // Журнал аудита
+    auth.GET("/guilds/:guildId/audit-logs", can(models.PermViewAuditLog), handlers.GetAuditLogs(db))
+
+    // Роли и права
+    auth.GET("/guilds/:guildId/permissions", member, handlers.GetMyPermissions())
+    auth.GET("/guilds/:guildId/roles", member, handlers.GetRoles(db))
+    auth.POST("/guilds/:guildId/roles", can(models.PermManageRoles), handlers.CreateRole(db))
+    auth.PATCH("/guilds/:guildId/roles/:roleId", can(models.PermManageRoles), handlers.UpdateRole(db))
+    auth.DELETE("/guilds/:guildId/roles/:roleId", can(models.PermManageRoles), handlers.DeleteRole(db))
+    auth.PUT("/guilds/:guildId/members/:userId/roles/:roleId", can(models.PermManageRoles), handlers.AddMemberRole(db))
+    auth.DELETE("/guilds/:guildId/members/:userId/roles/:roleId", can(models.PermManageRoles), handlers.RemoveMemberRole(db))
+
+    // Переопределения прав канала
+    auth.GET("/channels/:channelId/permissions", channel(0), handlers.GetMyChannelPermissions())
+    auth.GET("/channels/:channelId/members", channel(models.PermViewChannel), handlers.GetChannelMembers(db))
+    auth.PUT("/channels/:channelId/permissions/:targetId", channel(models.PermManageRoles), handlers.PutOverwrite(db))
+    auth.DELETE("/channels/:channelId/permissions/:targetId", channel(models.PermManageRoles), handlers.DeleteOverwrite(db))
     
+    // Внутренние вызовы других сервисов: только с SERVICE_TOKEN, через Kong не публикуются
+    internal := r.Group("/internal", middleware.ServiceAuth())
+    internal.POST("/audit-logs/channels", handlers.RecordChannelAudit(db))
+
     // 3. УДАЛИТЬ этот общий маршрут:
     // auth.GET("/:code", handlers.GetInvite(db))
   
@@ -36,11 +77,34 @@ func Register(r gin.IRouter, db *gorm.DB) {
     fmt.Println("GET /guilds")
     fmt.Println("POST /guilds")
     fmt.Println("GET /guilds/:guildId")
+    fmt.Println("PATCH /guilds/:guildId")
     fmt.Println("GET /guilds/:guildId/channels")
     fmt.Println("POST /guilds/:guildId/channels")
+    fmt.Println("GET /channels/:channelId")
     fmt.Println("GET /guilds/:guildId/members")
+    fmt.Println("GET /guilds/:guildId/members/:userId")
     fmt.Println("POST /guilds/:guildId/members")
     fmt.Println("POST /guilds/:guildId/invites")
+    fmt.Println("GET /guilds/:guildId/invites")
+    fmt.Println("DELETE /guilds/:guildId/invites/:code")
     fmt.Println("POST /invites/:code/accept")
+    fmt.Println("DELETE /guilds/:guildId/members/:userId")
+    fmt.Println("PATCH /guilds/:guildId/members/:userId")
+    fmt.Println("GET /guilds/:guildId/bans")
+    fmt.Println("PUT /guilds/:guildId/bans/:userId")
+    fmt.Println("DELETE /guilds/:guildId/bans/:userId")
+    fmt.Println("GET /guilds/:guildId/audit-logs")
+    fmt.Println("GET /guilds/:guildId/permissions")
+    fmt.Println("GET /guilds/:guildId/roles")
+    fmt.Println("POST /guilds/:guildId/roles")
+    fmt.Println("PATCH /guilds/:guildId/roles/:roleId")
+    fmt.Println("DELETE /guilds/:guildId/roles/:roleId")
+    fmt.Println("PUT /guilds/:guildId/members/:userId/roles/:roleId")
+    fmt.Println("DELETE /guilds/:guildId/members/:userId/roles/:roleId")
+    fmt.Println("GET /channels/:channelId/permissions")
+    fmt.Println("GET /channels/:channelId/members")
+    fmt.Println("PUT /channels/:channelId/permissions/:targetId")
+    fmt.Println("DELETE /channels/:channelId/permissions/:targetId")
+    fmt.Println("POST /internal/audit-logs/channels")
   }
 }
\ No newline at end of file
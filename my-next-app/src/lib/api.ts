@@ -98,8 +98,18 @@ export const guild = {
   addMember: (guildId: string, userId: string): Promise<AxiosResponse<void>> =>
     api.post(`/guilds/${guildId}/members/${userId}`),
 
-   createInvitation: (guildId: string): Promise<AxiosResponse<{ code: string }>> =>
-    api.post(`/guilds/${guildId}/invites`),
+   // maxAge в секундах (0 — бессрочное, по умолчанию 7 дней), maxUses 0 — без ограничения
+   createInvitation: (
+    guildId: string,
+    options?: { maxAge?: number; maxUses?: number; temporary?: boolean }
+  ): Promise<AxiosResponse<{ code: string }>> =>
+    api.post(`/guilds/${guildId}/invites`, options ?? {}),
+
+  getInvitations: (guildId: string): Promise<AxiosResponse<any[]>> =>
+    api.get(`/guilds/${guildId}/invites`),
+
+  deleteInvitation: (guildId: string, code: string): Promise<AxiosResponse<void>> =>
+    api.delete(`/guilds/${guildId}/invites/${code}`),
   
   acceptInvitation: (code: string): Promise<AxiosResponse<void>> =>
     api.post(`/invites/${code}/accept`), 
@@ -135,14 +145,20 @@ export const user = {
 
 // === Messages (Chat HTTP) ===
 export const message = {
-  // GET /channels/:channelId/messages?limit=&after=
+  // GET /channels/:channelId/messages?limit=&before=|after=|around=|cursor=
   getMessages: (
     channelId: string,
-    params?: { limit?: number; after?: string }
+    params?: { limit?: number; before?: string; after?: string; around?: string; cursor?: string }
   ): Promise<AxiosResponse<Message[]>> =>
     api.get(`/channels/${channelId}/messages`, { params }),
 
-  // NOTE: отправка сообщений идёт через WebSocket!
+  // POST /channels/:channelId/messages — то же, что MESSAGE_CREATE по WebSocket.
+  // Ответ 201 — новое сообщение, 200 — повтор с тем же nonce.
+  sendMessage: (
+    channelId: string,
+    data: { content: string; replyTo?: string; attachments?: string[]; nonce?: string }
+  ): Promise<AxiosResponse<Message>> =>
+    api.post(`/channels/${channelId}/messages`, data),
 };
 export type { 
   User, 
@@ -39,6 +39,9 @@ export class Gateway {
     this.socket.onmessage = (event) => {
       try {
         const rawData = JSON.parse(event.data);
+
+        // Пока обрабатываем только новые сообщения
+        if (rawData.type && rawData.type !== 'MESSAGE_CREATE') return;
         
         // Нормализация всех UUID полей
         const normalizedMessage: Message = {
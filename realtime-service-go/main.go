@@ -41,12 +41,25 @@ func main() {
 	}
 	defer consumer.Close()
 
-	// Создаём партиционный консьюмер
-	partitionConsumer, err := consumer.ConsumePartition("chat", 0, sarama.OffsetNewest)
+	// chat-service кладёт события с ключом guildId, так что гильдии
+	// распределены по партициям — читаем все
+	partitions, err := consumer.Partitions("chat")
 	if err != nil {
-		log.Fatalf("Partition consumer error: %v", err)
+		log.Fatalf("Kafka partitions error: %v", err)
+	}
+	messages := make(chan *sarama.ConsumerMessage)
+	for _, p := range partitions {
+		partitionConsumer, err := consumer.ConsumePartition("chat", p, sarama.OffsetNewest)
+		if err != nil {
+			log.Fatalf("Partition consumer error: %v", err)
+		}
+		defer partitionConsumer.Close()
+		go func(pc sarama.PartitionConsumer) {
+			for msg := range pc.Messages() {
+				messages <- msg
+			}
+		}(partitionConsumer)
 	}
-	defer partitionConsumer.Close()
 
 	// Обработка входящих WS-клиентов
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
@@ -88,7 +101,7 @@ func main() {
 
 	// Горутина чтения из Kafka и рассылки
 	go func() {
-		for msg := range partitionConsumer.Messages() {
+		for msg := range messages {
 			rooms.RLock()
 			conns := rooms.m[string(msg.Key)]
 			rooms.RUnlock()
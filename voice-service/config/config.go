@@ -9,8 +9,16 @@ var (
     RedisAddr  = mustGet("REDIS_ADDR")   // e.g. "redis:6379"
     RedisPass  = os.Getenv("REDIS_PASS") // если нужен
     JWTSecret  = mustGet("JWT_SECRET")
+    GuildServiceURL = getEnv("GUILD_SERVICE_URL", "http://guild-service:8080") // права на вход в голосовой канал
 )
 
+func getEnv(key, def string) string {
+    if v := os.Getenv(key); v != "" {
+        return v
+    }
+    return def
+}
+
 func mustGet(key string) string {
     v := os.Getenv(key)
     if v == "" {
@@ -0,0 +1,54 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/yourorg/voice-service/config"
+)
+
+// Биты прав guild-service, нужные для голоса
+const (
+	permViewChannel int64 = 1 << 10
+	permConnect     int64 = 1 << 20
+)
+
+var errNoAccess = errors.New("missing permissions")
+
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+// checkConnect спрашивает у guild-service права пользователя в канале (с его же токеном)
+// и пускает только с VIEW_CHANNEL и CONNECT
+func checkConnect(token, channelID string) error {
+	req, err := http.NewRequest(http.MethodGet, config.GuildServiceURL+"/channels/"+url.PathEscape(channelID)+"/permissions", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("guild-service: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden, http.StatusNotFound, http.StatusBadRequest:
+		return errNoAccess
+	default:
+		return fmt.Errorf("guild-service: status %d", resp.StatusCode)
+	}
+	var out struct {
+		Permissions int64 `json:"permissions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("guild-service: %w", err)
+	}
+	if want := permViewChannel | permConnect; out.Permissions&want != want {
+		return errNoAccess
+	}
+	return nil
+}
@@ -99,6 +99,13 @@ func ServeSignaling(rdb *redis.Client) gin.HandlerFunc {
 
 		log.Printf("client %s authenticated as %s", c.ClientIP(), userID)
 
+		// Войти можно только с VIEW_CHANNEL и CONNECT: их нет, например, у участника в тайм-ауте
+		if err := checkConnect(token, room); err != nil {
+			log.Printf("voice access denied user=%s room=%s: %v", userID, room, err)
+			conn.WriteJSON(gin.H{"error": err.Error()})
+			return
+		}
+
 		ctx := context.Background()
 		roomKey := "voice_room_users:" + room
 